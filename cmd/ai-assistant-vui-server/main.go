@@ -10,11 +10,15 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/mgoltzsche/ai-assistant-vui/internal/cli"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
 	"github.com/mgoltzsche/ai-assistant-vui/internal/server"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/storage"
 	"github.com/mgoltzsche/ai-assistant-vui/internal/tlsutils"
 	"github.com/mgoltzsche/ai-assistant-vui/internal/tools/mcp"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/vui"
 	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
 )
 
@@ -31,6 +35,7 @@ func main() {
 
 	flag.Var(configFlag, "config", "Path to the configuration file")
 	flag.StringVar(&cfg.ServerURL, "server-url", cfg.ServerURL, "URL pointing to the OpenAI API server that runs the LLM")
+	flag.StringVar(&cfg.ConversationDir, "conversation-dir", cfg.ConversationDir, "Directory to persist the channels' conversations within (disabled when empty)")
 	flag.StringVar(&listenAddr, "listen", listenAddr, "Address the server should listen on")
 	flag.StringVar(&webDir, "web-dir", webDir, "Path to the web UI directory")
 	flag.BoolVar(&tlsEnabled, "tls", tlsEnabled, "Serve securely via HTTPS/TLS")
@@ -61,7 +66,11 @@ func runServer(ctx context.Context, cfg config.Configuration, listenAddr, webDir
 		Handler:     mux,
 	}
 
-	mcpServers, err := mcp.NewServers(ctx, cfg.MCPServers)
+	mcpServers, err := mcp.NewServers(ctx, cfg.MCPServers, mcp.SupervisionPolicy{
+		HealthCheckInterval: time.Duration(cfg.MCPSupervision.HealthCheckInterval),
+		InitialBackoff:      time.Duration(cfg.MCPSupervision.InitialBackoff),
+		MaxBackoff:          time.Duration(cfg.MCPSupervision.MaxBackoff),
+	})
 	if err != nil {
 		return err
 	}
@@ -72,7 +81,23 @@ func runServer(ctx context.Context, cfg config.Configuration, listenAddr, webDir
 		}
 	}()
 
-	server.AddRoutes(ctx, cfg, mcpServers, webDir, mux)
+	chatBackends, err := vui.NewChatBackends(cfg, &http.Client{Timeout: 90 * time.Second})
+	if err != nil {
+		return err
+	}
+
+	var store model.ConversationStore
+
+	if cfg.ConversationDir != "" {
+		store, err = storage.NewFileStore(cfg.ConversationDir)
+		if err != nil {
+			return err
+		}
+	} else if cfg.Budget.DailyTokens > 0 {
+		slog.Warn("the daily token budget is reset when the server restarts since no conversation directory is configured")
+	}
+
+	server.AddRoutes(ctx, cfg, mcpServers, chatBackends, store, webDir, mux)
 
 	go func() {
 		<-ctx.Done()
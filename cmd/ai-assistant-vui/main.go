@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -63,6 +64,9 @@ func main() {
 	}
 }
 
+// localChannelID identifies the conversation with the local audio devices within prompt templates.
+const localChannelID = "local"
+
 func runAudioPipeline(ctx context.Context, cfg config.Configuration) (err error) {
 	audioDevice := &audio.Input{
 		Device:      cfg.InputDevice,
@@ -93,7 +97,11 @@ func runAudioPipeline(ctx context.Context, cfg config.Configuration) (err error)
 
 	wavAudioInput := audio.AudioBuffersToRiffWavs(audioInput)
 
-	mcpServers, err := mcp.NewServers(ctx, cfg.MCPServers)
+	mcpServers, err := mcp.NewServers(ctx, cfg.MCPServers, mcp.SupervisionPolicy{
+		HealthCheckInterval: time.Duration(cfg.MCPSupervision.HealthCheckInterval),
+		InitialBackoff:      time.Duration(cfg.MCPSupervision.InitialBackoff),
+		MaxBackoff:          time.Duration(cfg.MCPSupervision.MaxBackoff),
+	})
 	if err != nil {
 		return err
 	}
@@ -104,7 +112,17 @@ func runAudioPipeline(ctx context.Context, cfg config.Configuration) (err error)
 		}
 	}()
 
-	playbackRequests, conversation, err := vui.AudioPipeline(ctx, cfg, mcpServers, wavAudioInput)
+	chatBackends, err := vui.NewChatBackends(cfg, &http.Client{Timeout: 90 * time.Second})
+	if err != nil {
+		return err
+	}
+
+	conversation, err := vui.NewConversation(cfg, localChannelID)
+	if err != nil {
+		return err
+	}
+
+	playbackRequests, err := vui.AudioPipeline(ctx, cfg, mcpServers, chatBackends, localChannelID, conversation, wavAudioInput)
 	if err != nil {
 		return err
 	}
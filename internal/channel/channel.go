@@ -21,21 +21,26 @@ type Subscriber = pubsub.Subscriber[AudioMessage]
 type Publisher = pubsub.Publisher[AudioMessage]
 
 type Channel struct {
-	input  chan<- AudioMessage
-	output *pubsub.PubSub[AudioMessage]
-	cancel context.CancelFunc
+	input        chan<- AudioMessage
+	output       *pubsub.PubSub[AudioMessage]
+	conversation *model.Conversation
+	cancel       context.CancelFunc
+	profile      string
+	dailyTokens  int
 }
 
-func newChannel(ctx context.Context, cfg config.Configuration, mcpServers mcp.Servers, client *http.Client) (*Channel, error) {
+func newChannel(ctx context.Context, id string, cfg config.Configuration, mcpServers mcp.Servers, chatBackends *vui.ChatBackends, conversation *model.Conversation, client *http.Client) (*Channel, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	input := make(chan AudioMessage, 5)
 	c := &Channel{
-		input:  input,
-		output: pubsub.New[AudioMessage](),
-		cancel: cancel,
+		input:        input,
+		output:       pubsub.New[AudioMessage](),
+		conversation: conversation,
+		cancel:       cancel,
+		dailyTokens:  cfg.Budget.DailyTokens,
 	}
 
-	output, conversation, err := vui.AudioPipeline(ctx, cfg, mcpServers, input)
+	output, err := vui.AudioPipeline(ctx, cfg, mcpServers, chatBackends, id, conversation, input)
 	if err != nil {
 		return nil, fmt.Errorf("start conversation: %w", err)
 	}
@@ -43,6 +48,7 @@ func newChannel(ctx context.Context, cfg config.Configuration, mcpServers mcp.Se
 	go func() {
 		defer cancel()
 		defer c.output.Stop()
+		defer conversation.Flush()
 
 		for m := range output {
 			if m.RequestNum < conversation.RequestCounter() {
@@ -56,7 +62,7 @@ func newChannel(ctx context.Context, cfg config.Configuration, mcpServers mcp.Se
 			}
 
 			if m.UserOnly || conversation.AddAIResponse(m.RequestNum, m.Text) {
-				if m.UserOnly {
+				if m.UserOnly && m.Text != "" {
 					slog.Info(fmt.Sprintf("assistant: %s", m.Text))
 				}
 
@@ -75,6 +81,20 @@ func (c *Channel) Stop() {
 	close(c.input)
 }
 
+// Profile returns the name of the profile the channel uses (empty when using the top-level configuration).
+func (c *Channel) Profile() string {
+	return c.profile
+}
+
+// DailyTokenBudget returns the amount of tokens the channel may use per day according to its profile (unlimited when 0).
+func (c *Channel) DailyTokenBudget() int {
+	return c.dailyTokens
+}
+
+func (c *Channel) Conversation() *model.Conversation {
+	return c.conversation
+}
+
 func (c *Channel) Publish(msg AudioMessage) {
 	c.input <- msg
 }
@@ -1,49 +1,228 @@
 package channel
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
 	"github.com/mgoltzsche/ai-assistant-vui/internal/tools/mcp"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/vui"
 	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
 )
 
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrProfileConflict = errors.New("profile conflict")
+)
+
 type Channels struct {
-	ctx        context.Context
-	channels   map[string]*Channel
-	cfg        config.Configuration
-	mcpServers mcp.Servers
-	httpClient *http.Client
-	mutex      *sync.Mutex
+	ctx          context.Context
+	channels     map[string]*Channel
+	cfg          config.Configuration
+	mcpServers   mcp.Servers
+	chatBackends *vui.ChatBackends
+	store        model.ConversationStore
+	httpClient   *http.Client
+	mutex        *sync.Mutex
 }
 
-func NewChannels(ctx context.Context, cfg config.Configuration, mcpServers mcp.Servers) *Channels {
+// NewChannels creates a channel registry.
+// The store is optional - when nil, conversations are kept in memory only.
+func NewChannels(ctx context.Context, cfg config.Configuration, mcpServers mcp.Servers, chatBackends *vui.ChatBackends, store model.ConversationStore) *Channels {
 	return &Channels{
-		channels:   map[string]*Channel{},
-		httpClient: &http.Client{Timeout: 90 * time.Second},
-		mutex:      &sync.Mutex{},
-		cfg:        cfg,
-		mcpServers: mcpServers,
-		ctx:        ctx,
+		channels:     map[string]*Channel{},
+		httpClient:   &http.Client{Timeout: 90 * time.Second},
+		mutex:        &sync.Mutex{},
+		cfg:          cfg,
+		mcpServers:   mcpServers,
+		chatBackends: chatBackends,
+		store:        store,
+		ctx:          ctx,
 	}
 }
 
-func (r *Channels) GetOrCreate(id string) (*Channel, error) {
+// GetOrCreate returns the channel with the given ID, creating it if it does not exist yet.
+// A new channel uses the given profile or, if empty, the profile that is assigned to the channel within the configuration.
+// Requesting a different profile than the one of a running channel fails with ErrProfileConflict.
+func (r *Channels) GetOrCreate(id, profile string) (*Channel, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
 	c, ok := r.channels[id]
 	if !ok {
-		c, err := newChannel(r.ctx, r.cfg, r.mcpServers, r.httpClient)
+		profile = cmp.Or(profile, r.cfg.ChannelProfiles[id])
+
+		cfg, err := r.channelConfig(profile)
+		if err != nil {
+			return nil, err
+		}
+
+		conversation, err := r.loadConversation(id, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		c, err := newChannel(r.ctx, id, cfg, r.mcpServers, r.chatBackends, conversation, r.httpClient)
 		if err != nil {
 			return nil, err
 		}
 
+		c.profile = profile
+
+		if profile != "" {
+			slog.Info(fmt.Sprintf("started %s channel using profile %s", id, profile))
+		}
+
 		r.channels[id] = c
 		return c, nil
 	}
 
+	if profile != "" && profile != c.profile {
+		return nil, fmt.Errorf("channel %s is running with profile %q already: %w", id, c.profile, ErrProfileConflict)
+	}
+
 	return c, nil
 }
+
+// channelConfig returns the configuration with the given profile applied.
+func (r *Channels) channelConfig(profile string) (config.Configuration, error) {
+	if profile == "" {
+		return r.cfg, nil
+	}
+
+	if _, ok := r.cfg.Profiles[profile]; !ok {
+		return r.cfg, fmt.Errorf("profile %q: %w", profile, ErrNotFound)
+	}
+
+	return r.cfg.WithProfile(profile)
+}
+
+// DailyTokenBudget returns the daily token budget of the given channel (unlimited when 0).
+// When the channel is not running, the budget of the profile that is assigned to it within the configuration is returned.
+func (r *Channels) DailyTokenBudget(id string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if c, ok := r.channels[id]; ok {
+		return c.DailyTokenBudget(), nil
+	}
+
+	cfg, err := r.channelConfig(r.cfg.ChannelProfiles[id])
+	if err != nil {
+		return 0, err
+	}
+
+	return cfg.Budget.DailyTokens, nil
+}
+
+// ConversationState returns the state of the given channel's conversation without starting the channel.
+// When the channel is not running, its persisted state is returned.
+func (r *Channels) ConversationState(id string) (model.ConversationState, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if c, ok := r.channels[id]; ok {
+		return c.Conversation().State(), nil
+	}
+
+	state, found, err := r.loadState(id)
+	if err != nil {
+		return model.ConversationState{}, err
+	}
+
+	if !found {
+		return model.ConversationState{}, fmt.Errorf("channel %q: %w", id, ErrNotFound)
+	}
+
+	return state, nil
+}
+
+// ResetConversation drops all messages of the given channel's conversation except the system prompt.
+// When the channel is not running, its persisted conversation is reset.
+func (r *Channels) ResetConversation(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if c, ok := r.channels[id]; ok {
+		c.Conversation().Reset()
+		return nil
+	}
+
+	state, found, err := r.loadState(id)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return fmt.Errorf("channel %q: %w", id, ErrNotFound)
+	}
+
+	// The lock prevents the channel from being started while its persisted conversation is reset.
+	conversation, err := model.RestoreConversation(state)
+	if err != nil {
+		return fmt.Errorf("%s channel: %w", id, err)
+	}
+
+	conversation.Reset()
+
+	err = conversation.PersistTo(id, r.store)
+	if err != nil {
+		return fmt.Errorf("persist %s channel conversation: %w", id, err)
+	}
+
+	return nil
+}
+
+// loadState returns the persisted conversation state of the given channel and false if there is none.
+func (r *Channels) loadState(id string) (model.ConversationState, bool, error) {
+	if r.store == nil {
+		return model.ConversationState{}, false, nil
+	}
+
+	state, found, err := r.store.Load(id)
+	if err != nil {
+		return model.ConversationState{}, false, fmt.Errorf("load %s channel conversation: %w", id, err)
+	}
+
+	return state, found, nil
+}
+
+func (r *Channels) loadConversation(id string, cfg config.Configuration) (*model.Conversation, error) {
+	if r.store == nil {
+		return vui.NewConversation(cfg, id)
+	}
+
+	state, found, err := r.loadState(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var conversation *model.Conversation
+
+	if found {
+		slog.Info(fmt.Sprintf("restoring %s channel conversation", id))
+
+		conversation, err = model.RestoreConversation(state)
+		if err != nil {
+			return nil, fmt.Errorf("%s channel: %w", id, err)
+		}
+	} else {
+		conversation, err = vui.NewConversation(cfg, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = conversation.PersistTo(id, r.store)
+	if err != nil {
+		return nil, fmt.Errorf("persist %s channel conversation: %w", id, err)
+	}
+
+	return conversation, nil
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 
 	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
 	"github.com/mgoltzsche/ai-assistant-vui/internal/tools"
@@ -12,12 +14,58 @@ import (
 	"github.com/tmc/langchaingo/llms"
 )
 
+// AgentResultMode specifies what happens with the response of an agent.
+type AgentResultMode string
+
+const (
+	// AgentResultModeSpeak lets the agent answer the user directly.
+	AgentResultModeSpeak AgentResultMode = "speak"
+	// AgentResultModeReturn returns the agent's response as tool result to the calling LLM.
+	AgentResultModeReturn AgentResultMode = "return"
+)
+
+// ParseAgentResultMode returns the agent result mode with the given name.
+func ParseAgentResultMode(mode string) (AgentResultMode, error) {
+	switch m := AgentResultMode(mode); m {
+	case "":
+		return AgentResultModeSpeak, nil
+	case AgentResultModeSpeak, AgentResultModeReturn:
+		return m, nil
+	default:
+		return m, fmt.Errorf("unsupported agent result mode %q", mode)
+	}
+}
+
+// defaultMaxAgentDepth is the default maximum agent nesting depth.
+const defaultMaxAgentDepth = 3
+
 type Agent struct {
 	Name         string
 	Description  string
-	SystemPrompt string
+	SystemPrompt Prompt
 	Tools        tools.ToolProvider
 	LLM          LLM
+	ResultMode   AgentResultMode
+	// Agents are the agents this agent can delegate to.
+	Agents []*Agent
+	// MaxDepth is the maximum agent nesting depth up to which the agent may delegate to other agents (defaults to 3).
+	MaxDepth int
+}
+
+func (a *Agent) maxDepth() int {
+	if a.MaxDepth <= 0 {
+		return defaultMaxAgentDepth
+	}
+
+	return a.MaxDepth
+}
+
+type agentDepthKey struct{}
+
+// agentDepth returns the nesting depth of the agent that is running within the given context.
+func agentDepth(ctx context.Context) int {
+	depth, _ := ctx.Value(agentDepthKey{}).(int)
+	return depth
 }
 
 func (a *Agent) Definition() llms.FunctionDefinition {
@@ -38,12 +86,18 @@ func (a *Agent) Definition() llms.FunctionDefinition {
 	}
 }
 
-func (a *Agent) AsTool(reqNum int64, ch chan<- ResponseChunk) *AgentTool {
-	return &AgentTool{
+func (a *Agent) AsTool(reqNum int64, ch chan<- ResponseChunk) tools.Tool {
+	t := &AgentTool{
 		Agent:      a,
 		RequestNum: reqNum,
 		Ch:         ch,
 	}
+
+	if a.ResultMode == AgentResultModeReturn {
+		return &agentResultTool{agent: t}
+	}
+
+	return t
 }
 
 func (a *Agent) invoke(ctx context.Context, prompt string, reqNum int64, ch chan<- ResponseChunk) error {
@@ -52,7 +106,24 @@ func (a *Agent) invoke(ctx context.Context, prompt string, reqNum int64, ch chan
 		return err
 	}
 
-	conv := model.NewConversation(a.SystemPrompt, reqNum-1)
+	depth := agentDepth(ctx) + 1
+	ctx = context.WithValue(ctx, agentDepthKey{}, depth)
+
+	if len(a.Agents) > 0 {
+		if depth < a.maxDepth() {
+			for _, agent := range a.Agents {
+				tools = append(tools, agent.AsTool(reqNum, ch))
+			}
+		} else {
+			slog.Warn(fmt.Sprintf("agent %s cannot delegate to other agents since the maximum agent depth of %d is reached", a.Name, a.maxDepth()))
+		}
+	}
+
+	systemPrompt := a.SystemPrompt.render(PromptData{
+		Locale: requestLocale(ctx),
+		Tools:  toolNames(tools),
+	})
+	conv := model.NewConversation(systemPrompt, reqNum-1)
 
 	conv.AddUserRequest(llms.TextPart(prompt))
 
@@ -61,6 +132,11 @@ func (a *Agent) invoke(ctx context.Context, prompt string, reqNum int64, ch chan
 		return err
 	}
 
+	if ctx.Err() != nil {
+		// The user interrupted the request, cancelling nested agents as well.
+		return ctx.Err()
+	}
+
 	// TODO: ensure the agent called a function, otherwise retry.
 
 	return nil
@@ -91,23 +167,83 @@ func (a *AgentTool) Definition() llms.FunctionDefinition {
 }
 
 func (a *AgentTool) Call(ctx context.Context, arguments string) (string, error) {
+	err := a.callDelegated(ctx, arguments, a.Ch)
+	if err != nil {
+		return "", err
+	}
+
+	return "", &ResponseDelegated{errors.New("response delegated")}
+}
+
+func (a *AgentTool) callDelegated(ctx context.Context, arguments string, ch chan<- ResponseChunk) error {
 	args := map[string]any{}
 	if arguments != "" {
 		err := json.Unmarshal([]byte(arguments), &args)
 		if err != nil {
-			return "", fmt.Errorf("parse agent call arguments: %w", err)
+			return fmt.Errorf("parse agent call arguments: %w", err)
 		}
 	}
 
 	prompt, ok := args["prompt"].(string)
 	if !ok || prompt == "" {
-		return "", fmt.Errorf("no prompt provided for agent %s", a.Name)
+		return fmt.Errorf("no prompt provided for agent %s", a.Name)
 	}
 
-	err := a.invoke(ctx, prompt, a.RequestNum, a.Ch)
+	err := a.invoke(ctx, prompt, a.RequestNum, ch)
 	if err != nil {
-		return "", fmt.Errorf("run %s agent: %w", a.Name, err)
+		return fmt.Errorf("run %s agent: %w", a.Name, err)
 	}
 
-	return "", &ResponseDelegated{errors.New("response delegated")}
+	return nil
+}
+
+// agentResultTool returns the agent's response as tool result instead of speaking it.
+// This allows the calling LLM to build on the agent's findings.
+type agentResultTool struct {
+	agent *AgentTool
+}
+
+func (t *agentResultTool) Definition() llms.FunctionDefinition {
+	return t.agent.Definition()
+}
+
+func (t *agentResultTool) Call(ctx context.Context, arguments string) (string, error) {
+	ch := make(chan ResponseChunk)
+	collected := make(chan string)
+
+	go func() {
+		text := strings.Builder{}
+
+		for msg := range ch {
+			if msg.UserOnly {
+				if t.agent.Ch != nil {
+					t.agent.Ch <- msg
+				}
+
+				continue
+			}
+
+			if msg.Type == model.MessageTypeChunk {
+				text.WriteString(msg.Text)
+			}
+		}
+
+		collected <- strings.TrimSpace(text.String())
+	}()
+
+	err := t.agent.callDelegated(ctx, arguments, ch)
+
+	close(ch)
+
+	result := <-collected
+
+	if err != nil {
+		return "", err
+	}
+
+	if result == "" {
+		return fmt.Sprintf("The %s agent did not provide a result.", t.agent.Name), nil
+	}
+
+	return result, nil
 }
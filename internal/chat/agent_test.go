@@ -0,0 +1,102 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/tools"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentToolResultMode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"It is sunny \"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"in Berlin.\"}}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	for _, c := range []struct {
+		mode           AgentResultMode
+		expectResult   string
+		expectSpoken   int
+		expectDelegate bool
+	}{
+		{
+			mode:           AgentResultModeSpeak,
+			expectSpoken:   2,
+			expectDelegate: true,
+		},
+		{
+			mode:         AgentResultModeReturn,
+			expectResult: "It is sunny in Berlin.",
+		},
+	} {
+		t.Run(string(c.mode), func(t *testing.T) {
+			agent := &Agent{
+				Name:       "weather",
+				Tools:      tools.Noop(),
+				LLM:        LLM{Backends: []*Backend{{ServerURL: srv.URL, Model: "small"}}},
+				ResultMode: c.mode,
+			}
+			ch := make(chan ResponseChunk, 10)
+			testee := agent.AsTool(1, ch)
+
+			_, isDelegating := testee.(delegatingTool)
+			require.Equal(t, c.expectDelegate, isDelegating, "delegating tool")
+
+			result, err := testee.Call(context.Background(), `{"prompt":"How is the weather in Berlin?"}`)
+			if c.expectDelegate {
+				require.True(t, IsResponseDelegated(err), "response delegated")
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, c.expectResult, result, "result")
+
+			close(ch)
+			require.Len(t, ch, c.expectSpoken, "spoken chunks")
+		})
+	}
+}
+
+func TestAgentMaxDepth(t *testing.T) {
+	for _, c := range []struct {
+		maxDepth    int
+		expectTools []string
+	}{
+		{maxDepth: 1, expectTools: []string{}},
+		{maxDepth: 2, expectTools: []string{"playlist", "answer"}},
+	} {
+		t.Run(fmt.Sprintf("max depth %d", c.maxDepth), func(t *testing.T) {
+			offeredTools := []string{}
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				req := chatCompletionRequest{}
+				err := json.NewDecoder(r.Body).Decode(&req)
+				require.NoError(t, err)
+
+				for _, tool := range req.Tools {
+					offeredTools = append(offeredTools, tool.Function.Name)
+				}
+
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Okay.\"}}]}\n\ndata: [DONE]\n\n")
+			}))
+			defer srv.Close()
+
+			llm := LLM{Backends: []*Backend{{ServerURL: srv.URL, Model: "small"}}}
+			playlist := &Agent{Name: "playlist", Tools: tools.Noop(), LLM: llm}
+			music := &Agent{Name: "music", Tools: tools.Noop(), LLM: llm, Agents: []*Agent{playlist}, MaxDepth: c.maxDepth}
+			ch := make(chan ResponseChunk, 10)
+
+			_, err := music.AsTool(1, ch).Call(context.Background(), `{"prompt":"Play some jazz"}`)
+			require.True(t, IsResponseDelegated(err), "response delegated")
+			require.Equal(t, c.expectTools, offeredTools, "tools offered to the music agent")
+		})
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 type answerTool struct {
 	RequestNum int64
+	Voice      string
 	Ch         chan<- ResponseChunk
 }
 
@@ -35,24 +36,34 @@ func (f *answerTool) Definition() llms.FunctionDefinition {
 }
 
 func (f *answerTool) Call(ctx context.Context, arguments string) (string, error) {
+	err := f.callDelegated(ctx, arguments, f.Ch)
+	if err != nil {
+		return "", err
+	}
+
+	return "", &ResponseDelegated{errors.New("response delegated")}
+}
+
+func (f *answerTool) callDelegated(_ context.Context, arguments string, ch chan<- ResponseChunk) error {
 	args := map[string]any{}
 	if arguments != "" {
 		err := json.Unmarshal([]byte(arguments), &args)
 		if err != nil {
-			return "", fmt.Errorf("parse answer call arguments: %w", err)
+			return fmt.Errorf("parse answer call arguments: %w", err)
 		}
 	}
 
 	msg, ok := args["message"].(string)
 	if !ok || msg == "" {
-		return "", errors.New("no message provided")
+		return errors.New("no message provided")
 	}
 
-	f.Ch <- ResponseChunk{
+	ch <- ResponseChunk{
 		Type:       model.MessageTypeChunk,
 		RequestNum: f.RequestNum,
 		Text:       msg,
+		Voice:      f.Voice,
 	}
 
-	return "", &ResponseDelegated{errors.New("response delegated")}
+	return nil
 }
@@ -0,0 +1,259 @@
+package chat
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// RoutingStrategy specifies how a chat backend is selected for a request.
+type RoutingStrategy string
+
+const (
+	// RoutingFailover uses the first healthy backend in the configured order.
+	RoutingFailover RoutingStrategy = "failover"
+	// RoutingHealth prefers the healthy backend with the lowest load and latency.
+	RoutingHealth RoutingStrategy = "health"
+)
+
+// Backend is an OpenAI-compatible chat completion server.
+// A Backend may be shared between multiple LLMs and keeps track of its health.
+type Backend struct {
+	Name      string
+	ServerURL string
+	APIKey    string
+	Model     string
+	// MaxTokens limits the tokens generated per request (unlimited when 0).
+	MaxTokens int
+	// MaxConcurrency limits the number of concurrent requests (unlimited when 0).
+	MaxConcurrency int
+	// ThinkingMode is the backend's default thinking mode.
+	ThinkingMode ThinkingMode
+	HTTPClient   HTTPDoer
+
+	llm                 *openai.LLM
+	inFlight            int
+	consecutiveFailures int
+	unhealthyUntil      time.Time
+	latency             time.Duration
+	slots               chan struct{}
+	mutex               sync.Mutex
+}
+
+type HTTPDoer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+func (b *Backend) client() (*openai.LLM, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.llm == nil {
+		llm, err := openai.New(
+			openai.WithHTTPClient(b.httpClient()),
+			openai.WithBaseURL(b.ServerURL+"/v1"),
+			openai.WithToken(b.APIKey),
+			openai.WithModel(b.Model),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("chat backend %s: %w", b.Name, err)
+		}
+
+		b.llm = llm
+	}
+
+	return b.llm, nil
+}
+
+func (b *Backend) httpClient() HTTPDoer {
+	if b.HTTPClient == nil {
+		return http.DefaultClient
+	}
+
+	return b.HTTPClient
+}
+
+func (b *Backend) maxTokens(maxTokens int) int {
+	if b.MaxTokens > 0 && (maxTokens <= 0 || maxTokens > b.MaxTokens) {
+		return b.MaxTokens
+	}
+
+	return maxTokens
+}
+
+// acquire blocks until the backend can accept another request.
+// The returned function must be called with the request's result.
+func (b *Backend) acquire(ctx context.Context) (func(error), error) {
+	b.mutex.Lock()
+	if b.slots == nil && b.MaxConcurrency > 0 {
+		b.slots = make(chan struct{}, b.MaxConcurrency)
+	}
+	slots := b.slots
+	b.inFlight++
+	b.mutex.Unlock()
+
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			b.mutex.Lock()
+			b.inFlight--
+			b.mutex.Unlock()
+
+			return nil, ctx.Err()
+		}
+	}
+
+	start := time.Now()
+
+	return func(err error) {
+		if slots != nil {
+			<-slots
+		}
+
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+
+		b.inFlight--
+
+		if err == nil || errors.Is(err, context.Canceled) {
+			b.consecutiveFailures = 0
+			b.unhealthyUntil = time.Time{}
+
+			if err == nil {
+				b.recordLatency(time.Since(start))
+			}
+
+			return
+		}
+
+		if IsRetryable(classifyError(err)) {
+			b.consecutiveFailures++
+			b.unhealthyUntil = time.Now().Add(b.cooldown())
+		}
+	}, nil
+}
+
+// cooldown returns how long the backend is avoided after consecutive failures.
+func (b *Backend) cooldown() time.Duration {
+	cooldown := 5 * time.Second
+
+	for i := 1; i < b.consecutiveFailures && cooldown < time.Minute; i++ {
+		cooldown *= 2
+	}
+
+	return min(cooldown, time.Minute)
+}
+
+func (b *Backend) recordLatency(latency time.Duration) {
+	if b.latency == 0 {
+		b.latency = latency
+		return
+	}
+
+	b.latency = (4*b.latency + latency) / 5
+}
+
+type backendStatus struct {
+	Backend   *Backend
+	Healthy   bool
+	Saturated bool
+	Load      float64
+	Latency   time.Duration
+	Until     time.Time
+}
+
+func (b *Backend) status(now time.Time) backendStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s := backendStatus{
+		Backend: b,
+		Healthy: !now.Before(b.unhealthyUntil),
+		Latency: b.latency,
+		Until:   b.unhealthyUntil,
+		Load:    float64(b.inFlight),
+	}
+
+	if b.MaxConcurrency > 0 {
+		s.Saturated = b.inFlight >= b.MaxConcurrency
+		s.Load = float64(b.inFlight) / float64(b.MaxConcurrency)
+	}
+
+	return s
+}
+
+// backendCandidates returns the backends in the order they should be tried.
+// Unhealthy backends are moved to the end but are still used when no other backend is available.
+func (c *LLM) backendCandidates() []*Backend {
+	now := time.Now()
+	statuses := make([]backendStatus, len(c.Backends))
+
+	for i, b := range c.Backends {
+		statuses[i] = b.status(now)
+	}
+
+	slices.SortStableFunc(statuses, func(a, b backendStatus) int {
+		if a.Healthy != b.Healthy {
+			if a.Healthy {
+				return -1
+			}
+
+			return 1
+		}
+
+		if !a.Healthy {
+			return a.Until.Compare(b.Until)
+		}
+
+		if c.Routing == RoutingHealth {
+			if a.Load != b.Load {
+				return cmp.Compare(a.Load, b.Load)
+			}
+
+			return cmp.Compare(a.Latency, b.Latency)
+		}
+
+		// failover: keep the configured order but skip busy backends
+		if a.Saturated != b.Saturated {
+			if a.Saturated {
+				return 1
+			}
+
+			return -1
+		}
+
+		return 0
+	})
+
+	candidates := make([]*Backend, len(statuses))
+	for i, s := range statuses {
+		candidates[i] = s.Backend
+	}
+
+	return candidates
+}
+
+// selectBackend returns the preferred backend that has not been tried yet.
+// When all backends have been tried, the preferred one is returned.
+func (c *LLM) selectBackend(tried map[*Backend]bool) (*Backend, error) {
+	candidates := c.backendCandidates()
+	if len(candidates) == 0 {
+		return nil, errors.New("no chat backend configured")
+	}
+
+	for _, b := range candidates {
+		if !tried[b] {
+			return b, nil
+		}
+	}
+
+	return candidates[0], nil
+}
@@ -0,0 +1,134 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestChatCompletionFailover(t *testing.T) {
+	var primaryRequests atomic.Int32
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		primaryRequests.Add(1)
+		http.Error(w, "model is busy", http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer secondary.Close()
+
+	testee := &LLM{
+		Backends: []*Backend{
+			{Name: "primary", ServerURL: primary.URL, Model: "big"},
+			{Name: "secondary", ServerURL: secondary.URL, Model: "small"},
+		},
+		Retry: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	}
+
+	for i := 1; i <= 2; i++ {
+		conv := model.NewConversation("You are a helpful assistant.", 0)
+		reqNum := conv.AddUserRequest(llms.TextPart("Hi"))
+		ch := make(chan ResponseChunk, 10)
+
+		err := testee.ChatCompletion(context.Background(), reqNum, nil, conv, ch)
+		require.NoError(t, err)
+
+		close(ch)
+
+		chunks := []string{}
+		for c := range ch {
+			chunks = append(chunks, c.Text)
+		}
+
+		require.Equal(t, []string{"Hello"}, chunks, "response")
+	}
+
+	require.Equal(t, int32(1), primaryRequests.Load(), "requests to the unhealthy primary backend")
+}
+
+func TestChatCompletionNoRetryAfterPartialResponse(t *testing.T) {
+	var requests atomic.Int32
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+
+		panic(http.ErrAbortHandler) // cut the stream
+	})
+
+	primary := httptest.NewServer(handler)
+	defer primary.Close()
+
+	secondary := httptest.NewServer(handler)
+	defer secondary.Close()
+
+	testee := &LLM{
+		Backends: []*Backend{
+			{Name: "primary", ServerURL: primary.URL, Model: "big"},
+			{Name: "secondary", ServerURL: secondary.URL, Model: "small"},
+		},
+		Retry: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	}
+
+	conv := model.NewConversation("You are a helpful assistant.", 0)
+	reqNum := conv.AddUserRequest(llms.TextPart("Hi"))
+	ch := make(chan ResponseChunk, 10)
+
+	err := testee.ChatCompletion(context.Background(), reqNum, nil, conv, ch)
+	require.Error(t, err)
+
+	close(ch)
+
+	chunks := []string{}
+	for c := range ch {
+		chunks = append(chunks, c.Text)
+	}
+
+	require.Equal(t, []string{"Hello"}, chunks, "response")
+	require.Equal(t, int32(1), requests.Load(), "requests")
+}
+
+func TestBackendCandidates(t *testing.T) {
+	a := &Backend{Name: "a"}
+	b := &Backend{Name: "b", MaxConcurrency: 1, inFlight: 1}
+	c := &Backend{Name: "c", unhealthyUntil: time.Now().Add(time.Minute)}
+	d := &Backend{Name: "d", latency: time.Second}
+	e := &Backend{Name: "e", latency: time.Millisecond}
+
+	for _, tc := range []struct {
+		routing RoutingStrategy
+		expect  []*Backend
+	}{
+		{RoutingFailover, []*Backend{a, d, e, b, c}},
+		{RoutingHealth, []*Backend{a, e, d, b, c}},
+	} {
+		t.Run(string(tc.routing), func(t *testing.T) {
+			testee := &LLM{Backends: []*Backend{b, c, a, d, e}, Routing: tc.routing}
+
+			require.Equal(t, names(tc.expect), names(testee.backendCandidates()))
+		})
+	}
+}
+
+func names(backends []*Backend) []string {
+	result := make([]string, len(backends))
+	for i, b := range backends {
+		result[i] = b.Name
+	}
+
+	return result
+}
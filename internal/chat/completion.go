@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"sync"
+	"time"
 
 	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
 	"github.com/mgoltzsche/ai-assistant-vui/internal/tools"
@@ -13,22 +14,30 @@ import (
 
 type ChatCompletionRequest struct {
 	RequestNum int64
+	// Locale is the locale provided by the client, if any.
+	Locale string
 }
 
 type Completer struct {
-	LLM         LLM
-	IntroPrompt string
-	Tools       tools.ToolProvider
-	Agents      []Agent
+	LLM LLM
+	// SystemPrompt is rendered into the conversation's system prompt before every request (optional).
+	SystemPrompt Prompt
+	IntroPrompt  Prompt
+	Tools        tools.ToolProvider
+	Agents       []*Agent
+	// DailyTokenBudget is the amount of tokens the conversation may use per day (unlimited when 0).
+	DailyTokenBudget int
 }
 
+const budgetExceededMessage = "Sorry, I have used up my budget for today. Please try again tomorrow."
+
 func (c *Completer) Run(ctx context.Context, requests <-chan ChatCompletionRequest, conv *model.Conversation) (<-chan ResponseChunk, error) {
 	ch := make(chan ResponseChunk, 50)
 
 	go func() {
 		defer close(ch)
 
-		if c.IntroPrompt != "" {
+		if c.IntroPrompt != nil && !c.budgetExceeded(conv) {
 			wg := &sync.WaitGroup{}
 			wg.Add(1)
 
@@ -38,8 +47,11 @@ func (c *Completer) Run(ctx context.Context, requests <-chan ChatCompletionReque
 				defer wg.Done()
 
 				origPrompt := conv.SystemPrompt()
+				if c.SystemPrompt != nil {
+					origPrompt = c.SystemPrompt.render(PromptData{})
+				}
 
-				conv.SetSystemPrompt(fmt.Sprintf("%s\n%s", origPrompt, c.IntroPrompt))
+				conv.SetSystemPrompt(fmt.Sprintf("%s\n%s", origPrompt, c.IntroPrompt.render(PromptData{})))
 				conv.AddUserRequest(llms.TextPart("Hi")) // LocalAI 4 requires user message
 
 				err := c.LLM.ChatCompletion(ctx, conv.RequestCounter(), nil, conv, ch)
@@ -57,6 +69,23 @@ func (c *Completer) Run(ctx context.Context, requests <-chan ChatCompletionReque
 		}
 
 		for req := range requests {
+			if c.budgetExceeded(conv) {
+				slog.Warn(fmt.Sprintf("refusing request since the daily token budget of %d is exceeded", c.DailyTokenBudget))
+
+				ch <- ResponseChunk{
+					Type:       model.MessageTypeChunk,
+					RequestNum: req.RequestNum,
+					Text:       budgetExceededMessage,
+					UserOnly:   true,
+				}
+				ch <- ResponseChunk{
+					Type:       model.MessageTypeEnd,
+					RequestNum: req.RequestNum,
+				}
+
+				continue
+			}
+
 			tools, err := c.Tools.Tools(ctx)
 			if err != nil {
 				slog.Error("failed to load tools", "err", err)
@@ -72,25 +101,53 @@ func (c *Completer) Run(ctx context.Context, requests <-chan ChatCompletionReque
 				tools = append(tools, agent.AsTool(req.RequestNum, ch))
 			}
 
-			err = c.LLM.ChatCompletion(ctx, req.RequestNum, tools, conv, ch)
+			if c.SystemPrompt != nil {
+				conv.SetSystemPrompt(c.SystemPrompt.render(PromptData{
+					Locale: req.Locale,
+					Tools:  toolNames(tools),
+				}))
+			}
+
+			reqCtx := context.WithValue(ctx, localeKey{}, req.Locale)
+
+			err = c.LLM.ChatCompletion(reqCtx, req.RequestNum, tools, conv, ch)
 			if err != nil {
 				slog.Error("chat completion failed", "err", err)
 
 				ch <- ResponseChunk{
 					Type:       model.MessageTypeChunk,
 					RequestNum: req.RequestNum,
-					Text:       fmt.Sprintf("ERROR: Failed to generate AI response: %s", err),
+					Text:       UserErrorMessage(err),
+					UserOnly:   true,
 				}
 			}
 
+			usage := conv.RequestTokenUsage(req.RequestNum)
+			today := conv.DailyTokenUsage(time.Now())
+
+			slog.Info(fmt.Sprintf("request %d used %d tokens (%d prompt, %d completion), %d tokens used today",
+				req.RequestNum, usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens, today.TotalTokens))
 			slog.Debug("end of response")
 
 			ch <- ResponseChunk{
 				Type:       model.MessageTypeEnd,
 				RequestNum: req.RequestNum,
 			}
+
+			// Summarize requests that were evicted from the history after responding to not delay the response.
+			go func() {
+				err := conv.SummarizeEvicted(ctx, c.LLM.Summarize)
+				if err != nil {
+					slog.Warn("failed to summarize conversation history", "err", err)
+				}
+			}()
 		}
 	}()
 
 	return ch, nil
 }
+
+// budgetExceeded returns true when the conversation used up its daily token budget.
+func (c *Completer) budgetExceeded(conv *model.Conversation) bool {
+	return c.DailyTokenBudget > 0 && conv.DailyTokenUsage(time.Now()).TotalTokens >= c.DailyTokenBudget
+}
@@ -6,14 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
 	"github.com/mgoltzsche/ai-assistant-vui/internal/tools"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
 )
 
 type ResponseChunk = model.Message
@@ -28,50 +27,45 @@ func IsResponseDelegated(err error) bool {
 }
 
 type LLM struct {
-	ServerURL           string
-	APIKey              string
-	Model               string
+	Backends            []*Backend
+	Routing             RoutingStrategy
 	Temperature         float64
 	FrequencyPenalty    float64
 	MaxTokens           int
 	StripResponsePrefix string
 	MaxTurns            int
-	HTTPClient          HTTPDoer
-
-	llm *openai.LLM
-}
-
-type HTTPDoer interface {
-	Do(*http.Request) (*http.Response, error)
+	ToolCallMode        ToolCallMode
+	ToolChoice          string
+	// ThinkingMode overrides the backend's thinking mode, e.g. for an agent.
+	ThinkingMode ThinkingMode
+	// DefaultThinkingMode is used when neither the LLM nor the backend specify a thinking mode.
+	DefaultThinkingMode ThinkingMode
+	Retry               RetryPolicy
+	ToolExecution       ToolExecutionPolicy
+	LoopPrevention      tools.LoopPolicy
+	AgentOrchestration  AgentOrchestration
+	// Voice is the TTS voice the responses are spoken with (default voice when empty).
+	Voice string
+	// Agent is the name of the agent the LLM is used by, used to attribute token usage.
+	Agent string
+	// Usage records the token usage of all chat completions.
+	Usage UsageRecorder
 }
 
 func (c *LLM) ChatCompletion(ctx context.Context, reqNum int64, fn []tools.Tool, conv *model.Conversation, ch chan<- ResponseChunk) error {
-	if c.llm == nil {
-		llm, err := openai.New(
-			openai.WithHTTPClient(c.HTTPClient),
-			openai.WithBaseURL(c.ServerURL+"/v1"),
-			openai.WithToken(c.APIKey),
-			openai.WithModel(c.Model),
-		)
-		if err != nil {
-			return err
-		}
-
-		c.llm = llm
-	}
-
 	if len(fn) > 0 {
 		// Add an answer function when there are functions defined.
 		// This is because the LLM tries to call it anyway and returns an error if the function doesn't exist.
 		// It happens since using LocalAI 4 and qwen3.
 		fn = append(fn, &answerTool{
 			RequestNum: reqNum,
+			Voice:      c.Voice,
 			Ch:         ch,
 		})
 	}
 
 	// TODO: align [SAY]
-	fns := tools.NewCallLoopPreventingProvider(fn)
+	fns := tools.NewCallLoopPreventingProvider(fn, c.LoopPrevention)
 	// TODO: add function to let the LLM say something to the user while using tools?
 	// On the the one hand this might provide good UX when the LLM provides dynamic feedback (alternative to a reasoning argument).
 	// On the other hand the client could require the LLM to always respond with a function call - no special cases, no accidental reading of function call JSON aloud and function call JSON would always be parsed via StreamingFunc.
@@ -81,18 +75,39 @@ func (c *LLM) ChatCompletion(ctx context.Context, reqNum int64, fn []tools.Tool,
 		RequestNum: reqNum,
 		Ch:         ch,
 	})*/
-	turn := 0
-
-	for {
-		turn++
-
+	for turn := 1; ; turn++ {
 		if c.MaxTurns > 0 && turn > c.MaxTurns {
 			slog.Warn(fmt.Sprintf("maximum LLM conversation turns of %d was exceeded for the request", c.MaxTurns))
 
-			fns = tools.NewCallLoopPreventingProvider(nil)
+			fns = tools.NewCallLoopPreventingProvider(nil, c.LoopPrevention)
 		}
 
-		err := c.createChatCompletion(ctx, reqNum, fns, conv, ch)
+		err := c.createChatCompletionWithRetry(ctx, reqNum, turn, fns, conv, ch)
+		if err == nil {
+			return nil
+		}
+
+		if _, ok := err.(*reconcileError); !ok {
+			return err
+		}
+	}
+}
+
+// createChatCompletionWithRetry requests a chat completion, retrying failed requests with exponential backoff.
+// When a request fails, the next backend is tried immediately if there is one.
+// A request is not retried once parts of its response have been sent to the user since they would be spoken twice.
+func (c *LLM) createChatCompletionWithRetry(ctx context.Context, reqNum int64, turn int, fns *tools.CallLoopPreventingProvider, conv *model.Conversation, ch chan<- ResponseChunk) error {
+	tried := make(map[*Backend]bool, len(c.Backends))
+
+	backend, err := c.selectBackend(tried)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		emitted := false
+
+		err := c.createChatCompletion(ctx, backend, reqNum, turn, fns, conv, ch, &emitted)
 		if err == nil {
 			return nil
 		}
@@ -101,13 +116,56 @@ func (c *LLM) ChatCompletion(ctx context.Context, reqNum int64, fn []tools.Tool,
 			return ctx.Err()
 		}
 
-		if _, ok := err.(*reconcileError); !ok {
-			slog.Warn(err.Error())
+		if _, ok := err.(*reconcileError); ok {
+			return err
+		}
+
+		if emitted {
+			return fmt.Errorf("chat completion failed after the response was partially sent: %w", classifyError(err))
+		}
+
+		err = classifyError(err)
+
+		var completionErr *CompletionError
+		errors.As(err, &completionErr)
+
+		if !IsRetryable(err) && completionErr.Kind != ErrorKindAuthentication {
+			return err
+		}
+
+		if attempt >= c.Retry.maxAttempts() {
+			return fmt.Errorf("giving up chat completion after %d attempts: %w", attempt, err)
+		}
+
+		tried[backend] = true
+
+		next, _ := c.selectBackend(tried)
+		if next != backend && !tried[next] {
+			slog.Warn(fmt.Sprintf("chat completion using backend %s failed, failing over to backend %s: %s", backend.Name, next.Name, err))
+
+			backend = next
+
+			continue
+		}
+
+		if !IsRetryable(err) {
+			return err
+		}
+
+		backend = next
+		backoff := c.Retry.backoff(attempt)
+
+		slog.Warn(fmt.Sprintf("chat completion attempt %d failed, retrying in %s: %s", attempt, backoff, err))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
 		}
 	}
 }
 
-func (c *LLM) createChatCompletion(ctx context.Context, reqNum int64, fns *tools.CallLoopPreventingProvider, conv *model.Conversation, ch chan<- ResponseChunk) error {
+func (c *LLM) createChatCompletion(ctx context.Context, backend *Backend, reqNum int64, turn int, fns *tools.CallLoopPreventingProvider, conv *model.Conversation, ch chan<- ResponseChunk, emitted *bool) error {
 	if conv.RequestCounter() > reqNum {
 		return nil // skip outdated request (user requested something else)
 	}
@@ -127,17 +185,125 @@ func (c *LLM) createChatCompletion(ctx context.Context, reqNum int64, fns *tools
 		llmFunctions[i] = f.Definition()
 	}
 
+	var toolChoice any
+	if len(llmFunctions) > 0 {
+		toolChoice = c.toolChoice(turn)
+	}
+
 	messages := conv.Messages()
 
 	printMessages(messages)
 
+	slog.Debug(fmt.Sprintf("requesting chat completion from backend %s using model %s", backend.Name, backend.Model))
+
+	release, err := backend.acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	var toolCalls []llms.ToolCall
+
+	if c.ToolCallMode == ToolCallModeLegacy {
+		toolCalls, err = c.createLegacyChatCompletion(ctx, backend, reqNum, messages, llmFunctions, toolChoice, conv, ch, emitted)
+	} else {
+		toolCalls, err = c.createNativeChatCompletion(ctx, backend, reqNum, messages, llmFunctions, toolChoice, conv, ch, emitted)
+	}
+
+	release(err)
+
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+
+		return err
+	}
+
+	if len(toolCalls) > 0 {
+		return c.handleToolCalls(ctx, toolCalls, reqNum, fns, conv, ch)
+	}
+
+	return nil
+}
+
+// createNativeChatCompletion requests a chat completion using the tools API and returns the assembled tool calls.
+func (c *LLM) createNativeChatCompletion(ctx context.Context, backend *Backend, reqNum int64, messages []llms.MessageContent, functions []llms.FunctionDefinition, toolChoice any, conv *model.Conversation, ch chan<- ResponseChunk, emitted *bool) ([]llms.ToolCall, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chatMessages, err := toChatMessages(messages)
+	if err != nil {
+		return nil, fmt.Errorf("convert messages: %w", err)
+	}
+
+	req := chatCompletionRequest{
+		Messages:         chatMessages,
+		ToolChoice:       toolChoice,
+		Temperature:      c.Temperature,
+		FrequencyPenalty: c.FrequencyPenalty,
+		MaxTokens:        backend.maxTokens(c.MaxTokens),
+	}
+
+	for _, f := range functions {
+		req.Tools = append(req.Tools, chatTool{Type: "function", Function: f})
+	}
+
+	c.thinkingMode(backend).apply(&req)
+
+	filter := &reasoningFilter{}
+
+	result, err := backend.streamChatCompletion(ctx, req, func(chunk string) error {
+		if conv.RequestCounter() > reqNum {
+			// Cancel response stream if request is outdated (user requested something else)
+			cancel()
+			return context.Canceled
+		}
+
+		slog.Debug(fmt.Sprintf("received chunk %q", chunk))
+
+		if text := filter.Write(chunk); text != "" {
+			c.emitResponseChunk(text, reqNum, ch, emitted)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if text := filter.Flush(); text != "" {
+		c.emitResponseChunk(text, reqNum, ch, emitted)
+	}
+
+	addReasoning(conv, reqNum, result.Reasoning, filter.Reasoning())
+	c.recordUsage(reqNum, c.agentName(), backend, result.Usage)
+
+	if len(result.ToolCalls) > 0 && strings.TrimSpace(result.Content) != "" {
+		slog.Debug("LLM responded with text in addition to tool calls", "text", result.Content)
+	}
+
+	return result.ToolCalls, nil
+}
+
+// createLegacyChatCompletion requests a chat completion using langchaingo's function calling support.
+// Tool calls are detected by parsing streamed chunks that look like a JSON list of tool calls.
+func (c *LLM) createLegacyChatCompletion(ctx context.Context, backend *Backend, reqNum int64, messages []llms.MessageContent, functions []llms.FunctionDefinition, toolChoice any, conv *model.Conversation, ch chan<- ResponseChunk, emitted *bool) ([]llms.ToolCall, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	var toolCalls []aiToolCall
 
+	filter := &reasoningFilter{}
+	emit := func(chunk string) {
+		if text := filter.Write(chunk); text != "" {
+			c.emitResponseChunk(text, reqNum, ch, emitted)
+		}
+	}
 	streamingFunc := func(_ context.Context, chunk []byte) error {
-		c.emitResponseChunk(string(chunk), reqNum, ch)
+		emit(string(chunk))
 		return nil
 	}
-	if len(llmFunctions) > 0 {
+	if len(functions) > 0 {
 		functionCalled := false
 
 		streamingFunc = func(_ context.Context, chunk []byte) error {
@@ -148,7 +314,7 @@ func (c *LLM) createChatCompletion(ctx context.Context, reqNum int64, fns *tools
 					// or the answer in addition to an 'answer' function call.
 					slog.Warn("ignoring unexpected chunk after function call", "chunk", chunk)
 				} else {
-					c.emitResponseChunk(chunkStr, reqNum, ch)
+					emit(chunkStr)
 				}
 
 				return nil
@@ -159,7 +325,7 @@ func (c *LLM) createChatCompletion(ctx context.Context, reqNum int64, fns *tools
 			err := json.Unmarshal(chunk, &addToolCalls)
 			if err != nil {
 				slog.Warn("failed to parse tool calls from chunk", "err", err, "chunk", chunk)
-				c.emitResponseChunk(string(chunk), reqNum, ch)
+				emit(string(chunk))
 
 				return nil
 			}
@@ -188,147 +354,92 @@ func (c *LLM) createChatCompletion(ctx context.Context, reqNum int64, fns *tools
 		return streamingFunc(ctx, chunk)
 	}
 
-	_, err = c.llm.GenerateContent(ctx,
-		messages,
+	opts := []llms.CallOption{
 		llms.WithStreamingFunc(streamingFuncWrapper),
-		//llms.WithTools(tools),
-		llms.WithFunctions(llmFunctions),
+		llms.WithFunctions(functions),
 		llms.WithTemperature(c.Temperature),
 		llms.WithFrequencyPenalty(c.FrequencyPenalty),
-		llms.WithMaxTokens(c.MaxTokens),
+		llms.WithMaxTokens(backend.maxTokens(c.MaxTokens)),
 		llms.WithPromptCaching(true),
-		llms.WithThinkingMode(llms.ThinkingModeNone),
+		c.thinkingMode(backend).callOption(),
 		llms.WithMetadata(map[string]any{}),
-	)
+	}
+	if toolChoice != nil {
+		opts = append(opts, llms.WithToolChoice(toolChoice))
+	}
+
+	llm, err := backend.client()
 	if err != nil {
-		if errors.Is(err, context.Canceled) {
-			return nil
-		}
+		return nil, err
+	}
 
-		return err
+	resp, err := llm.GenerateContent(ctx, messages, opts...)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(toolCalls) > 0 {
-		toolCalls = mergeToolCalls(toolCalls)
+	if text := filter.Flush(); text != "" {
+		c.emitResponseChunk(text, reqNum, ch, emitted)
+	}
 
-		for _, call := range toolCalls {
-			if call.Type != "function" || call.FunctionCall == nil {
-				slog.Warn(fmt.Sprintf("ignoring unsupported tool call: %#v", call))
-				continue
-			}
+	reasoning := ""
+	if len(resp.Choices) > 0 {
+		reasoning = resp.Choices[0].ReasoningContent
+	}
 
-			if conv.RequestCounter() > reqNum {
-				return nil // skip outdated request (user requested something else)
-			}
+	addReasoning(conv, reqNum, reasoning, filter.Reasoning())
+	c.recordUsage(reqNum, c.agentName(), backend, usageFromResponse(resp))
 
-			err := handleToolCall(ctx, call.ToolCall(), reqNum, fns, conv, ch)
-			if err != nil {
-				if IsResponseDelegated(err) {
-					// TODO: support using multiple agents/request (one for each task, e.g. change volume AND research sth)
-					return nil // toolified agent answered to the user directly
-				}
+	toolCalls = mergeToolCalls(toolCalls)
+	calls := make([]llms.ToolCall, 0, len(toolCalls))
 
-				return fmt.Errorf("failed to call tool %q: %w", call.FunctionCall.Name, err)
-			}
+	for _, call := range toolCalls {
+		if call.Type != "function" || call.FunctionCall == nil {
+			slog.Warn(fmt.Sprintf("ignoring unsupported tool call: %#v", call))
+			continue
 		}
 
-		return &reconcileError{fmt.Errorf("needs reconciliation")}
+		calls = append(calls, call.ToolCall())
 	}
 
-	return nil
+	return calls, nil
 }
 
-func (c *LLM) emitResponseChunk(chunk string, reqNum int64, ch chan<- ResponseChunk) {
+// emitResponseChunk sends a response chunk to the user and records that the response has been (partially) sent.
+func (c *LLM) emitResponseChunk(chunk string, reqNum int64, ch chan<- ResponseChunk, emitted *bool) {
+	*emitted = true
+
 	ch <- ResponseChunk{
 		Type:       model.MessageTypeChunk,
 		RequestNum: reqNum,
 		Text:       strings.TrimPrefix(chunk, c.StripResponsePrefix),
+		Voice:      c.Voice,
 	}
 }
 
-type reconcileError struct {
-	error
-}
+// addReasoning stores the reasoning the model returned separately or within think tags.
+func addReasoning(conv *model.Conversation, reqNum int64, reasoning ...string) {
+	parts := make([]string, 0, len(reasoning))
 
-func handleToolCall(ctx context.Context, toolCall llms.ToolCall, reqNum int64, fns *tools.CallLoopPreventingProvider, conv *model.Conversation, ch chan<- ResponseChunk) error {
-	call := toolCall.FunctionCall
-
-	callAllowed, err := fns.IsToolCallAllowed(call)
-	if err != nil {
-		return fmt.Errorf("deduplicate tool call: %w", err)
-	}
-
-	if !callAllowed {
-		// Re-request chat completion without the now banned tool
-		return fmt.Errorf("repeating tool call %q is not allowed", call.Name)
-	}
-
-	if toolCall.FunctionCall.Name != "answer" {
-		infos := []string{fmt.Sprintf("Let me use my %q tool.", call.Name)}
-		for _, sentence := range infos {
-			ch <- ResponseChunk{
-				Type:       model.MessageTypeChunk,
-				RequestNum: reqNum,
-				Text:       sentence,
-				UserOnly:   true,
-			}
+	for _, r := range reasoning {
+		if r = strings.TrimSpace(r); r != "" {
+			parts = append(parts, r)
 		}
 	}
 
-	result, err := callTool(ctx, toolCall, fns)
-	if err != nil {
-		if IsResponseDelegated(err) {
-			return err
-		}
-
-		msg := fmt.Sprintf("failed to call tool %q: %s", call.Name, err)
-		result = fmt.Sprintf("ERROR: %s", msg)
-
-		slog.Warn(msg)
+	if len(parts) == 0 {
+		return
 	}
 
-	conv.AddToolCallResponse(reqNum, toolCall, result)
+	text := strings.Join(parts, "\n")
 
-	return nil
-}
-
-func callTool(ctx context.Context, call llms.ToolCall, fns *tools.CallLoopPreventingProvider) (string, error) {
-	slog.Debug(fmt.Sprintf("%s tool call %s with args %#v", call.FunctionCall.Name, call.ID, call.FunctionCall.Arguments))
-
-	fnList, err := fns.Tools(ctx)
-	if err != nil {
-		return "", err
-	}
-
-	fn, err := tools.FindByName(call.FunctionCall.Name, fnList)
-	if err != nil {
-		return "", err
-	}
-
-	err = validateFunctionCallArgs(call.FunctionCall.Arguments, fn.Definition())
-	if err != nil {
-		return "", err
-	}
+	slog.Debug(fmt.Sprintf("reasoning: %s", text))
 
-	functionCallResult, err := fn.Call(ctx, call.FunctionCall.Arguments)
-	if err != nil {
-		return "", err
-	}
-
-	functionCallResult = strings.TrimSpace(functionCallResult)
-
-	if functionCallResult == "" {
-		return "", errors.New("tool call returned empty result")
-	}
-
-	result := ""
-	if len(functionCallResult) > 0 {
-		result = strings.ReplaceAll("\n"+functionCallResult, "\n", "\n\t")
-	}
-
-	slog.Debug(fmt.Sprintf("%s tool result: %s", call.FunctionCall.Name, result))
+	conv.AddReasoning(reqNum, text)
+}
 
-	return result, nil
+type reconcileError struct {
+	error
 }
 
 var whitespaceRegex = regexp.MustCompile(`\s+`)
@@ -351,65 +462,11 @@ func printMessages(messages []llms.MessageContent) {
 	slog.Debug(fmt.Sprintf("requesting chat completion for message history: %s", strings.Join(msgs, "")))
 }
 
-func validateFunctionCallArgs(args string, paramDefinition llms.FunctionDefinition) error {
-	if len(args) == 0 {
-		return errors.New("function called with empty arguments")
-	}
-
-	// TODO: validate parameters
-
-	return nil
-}
-
-func mergeToolCalls(calls []aiToolCall) []aiToolCall {
-	callMap := make(map[string]aiToolCall, len(calls))
-	ids := make([]string, 0, len(calls))
-	result := make([]aiToolCall, 0, len(calls))
-
-	for _, call := range calls {
-		if call.FunctionCall == nil {
-			continue
-		}
-
-		if lastCall, ok := callMap[call.ID]; ok {
-			if lastCall.FunctionCall.Name != "" {
-				call.FunctionCall.Name = lastCall.FunctionCall.Name
-			}
-			if lastCall.FunctionCall.Arguments != "" {
-				call.FunctionCall.Arguments = lastCall.FunctionCall.Arguments
-			}
-		} else {
-			ids = append(ids, call.ID)
-		}
-
-		callMap[call.ID] = call
-	}
-
-	for _, id := range ids {
-		result = append(result, callMap[id])
-	}
-
-	return result
-}
-
-type aiToolCall struct {
-	ID           string        `json:"id"`
-	Type         string        `type:"type"`
-	FunctionCall *functionCall `json:"function,omitempty"`
-}
-
-func (c *aiToolCall) ToolCall() llms.ToolCall {
-	return llms.ToolCall{
-		ID:   c.ID,
-		Type: c.Type,
-		FunctionCall: &llms.FunctionCall{
-			Name:      c.FunctionCall.Name,
-			Arguments: c.FunctionCall.Arguments,
-		},
+func findTool(ctx context.Context, name string, fns tools.ToolProvider) (tools.Tool, error) {
+	fnList, err := fns.Tools(ctx)
+	if err != nil {
+		return nil, err
 	}
-}
 
-type functionCall struct {
-	Name      string `json:"name"`
-	Arguments string `Json:"arguments"`
+	return tools.FindByName(name, fnList)
 }
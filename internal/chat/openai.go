@@ -0,0 +1,354 @@
+package chat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// chatCompletionRequest is an OpenAI-compatible streaming chat completion request using the tools API.
+type chatCompletionRequest struct {
+	Model            string         `json:"model,omitempty"`
+	Messages         []chatMessage  `json:"messages"`
+	Tools            []chatTool     `json:"tools,omitempty"`
+	ToolChoice       any            `json:"tool_choice,omitempty"`
+	Temperature      float64        `json:"temperature"`
+	FrequencyPenalty float64        `json:"frequency_penalty,omitempty"`
+	MaxTokens        int            `json:"max_tokens,omitempty"`
+	Stream           bool           `json:"stream"`
+	StreamOptions    *streamOptions `json:"stream_options,omitempty"`
+	// ReasoningEffort is supported by OpenAI reasoning models.
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+	// ChatTemplateKwargs is supported by llama.cpp-based servers to toggle thinking of models like qwen3.
+	ChatTemplateKwargs map[string]any `json:"chat_template_kwargs,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    any            `json:"content"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+type chatContentPart struct {
+	Type       string          `json:"type"`
+	Text       string          `json:"text,omitempty"`
+	ImageURL   *chatImageURL   `json:"image_url,omitempty"`
+	InputAudio *chatInputAudio `json:"input_audio,omitempty"`
+}
+
+type chatImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type chatInputAudio struct {
+	Data   string `json:"data"`
+	Format string `json:"format"`
+}
+
+type chatTool struct {
+	Type     string                  `json:"type"`
+	Function llms.FunctionDefinition `json:"function"`
+}
+
+type chatToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function chatFunctionCall `json:"function"`
+}
+
+type chatFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// chatCompletionChunk is a streamed chat completion response chunk.
+type chatCompletionChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string          `json:"content"`
+			ReasoningContent string          `json:"reasoning_content"`
+			Reasoning        string          `json:"reasoning"`
+			ToolCalls        []toolCallDelta `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *chatUsage `json:"usage,omitempty"`
+	Error *chatError `json:"error,omitempty"`
+}
+
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type chatError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    any    `json:"code"`
+}
+
+// chatCompletionResult is the assembled result of a streamed chat completion.
+type chatCompletionResult struct {
+	Content      string
+	Reasoning    string
+	ToolCalls    []llms.ToolCall
+	FinishReason string
+	Usage        model.TokenUsage
+}
+
+// streamChatCompletion requests a streamed chat completion from the OpenAI-compatible API.
+// Content chunks are passed to onContent while reasoning and tool call deltas are assembled into the returned result.
+func (b *Backend) streamChatCompletion(ctx context.Context, req chatCompletionRequest, onContent func(string) error) (*chatCompletionResult, error) {
+	req.Model = b.Model
+	req.Stream = true
+	req.StreamOptions = &streamOptions{IncludeUsage: true}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal chat completion request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, b.ServerURL+"/v1/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	if b.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+b.APIKey)
+	}
+
+	resp, err := b.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request chat completion: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var errResp struct {
+			Error chatError `json:"error"`
+		}
+
+		if json.Unmarshal(msg, &errResp) == nil && errResp.Error.Message != "" {
+			msg = []byte(errResp.Error.Message)
+		}
+
+		return nil, fmt.Errorf("chat completion API returned unexpected status code: %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	result := &chatCompletionResult{}
+	toolCalls := &toolCallAssembler{}
+	content := strings.Builder{}
+	reasoning := strings.Builder{}
+	scanner := bufio.NewScanner(resp.Body)
+
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue // skip empty lines, comments and other SSE fields
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk chatCompletionChunk
+
+		err := json.Unmarshal([]byte(data), &chunk)
+		if err != nil {
+			return nil, fmt.Errorf("parse chat completion chunk %q: %w", data, err)
+		}
+
+		if chunk.Error != nil {
+			return nil, fmt.Errorf("chat completion stream returned error: %s", chunk.Error.Message)
+		}
+
+		if chunk.Usage != nil {
+			result.Usage = model.TokenUsage{
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:      chunk.Usage.TotalTokens,
+			}
+		}
+
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		choice := chunk.Choices[0]
+
+		if choice.FinishReason != "" {
+			result.FinishReason = choice.FinishReason
+		}
+
+		toolCalls.Add(choice.Delta.ToolCalls)
+		reasoning.WriteString(choice.Delta.ReasoningContent)
+		reasoning.WriteString(choice.Delta.Reasoning)
+
+		if choice.Delta.Content != "" {
+			content.WriteString(choice.Delta.Content)
+
+			err = onContent(choice.Delta.Content)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("read chat completion stream: %w", err)
+	}
+
+	result.Content = content.String()
+	result.Reasoning = strings.TrimSpace(reasoning.String())
+	result.ToolCalls = toolCalls.ToolCalls()
+
+	return result, nil
+}
+
+// toChatMessages converts the conversation messages into the OpenAI wire format.
+func toChatMessages(messages []llms.MessageContent) ([]chatMessage, error) {
+	result := make([]chatMessage, 0, len(messages))
+
+	for _, m := range messages {
+		switch m.Role {
+		case llms.ChatMessageTypeTool:
+			for _, part := range m.Parts {
+				resp, ok := part.(llms.ToolCallResponse)
+				if !ok {
+					return nil, fmt.Errorf("unsupported %T part within tool message", part)
+				}
+
+				result = append(result, chatMessage{
+					Role:       "tool",
+					Content:    resp.Content,
+					ToolCallID: resp.ToolCallID,
+				})
+			}
+		case llms.ChatMessageTypeAI:
+			msg := chatMessage{Role: "assistant"}
+			text := make([]string, 0, 1)
+
+			for _, part := range m.Parts {
+				switch p := part.(type) {
+				case llms.TextContent:
+					text = append(text, p.Text)
+				case llms.ToolCall:
+					if p.FunctionCall == nil {
+						continue
+					}
+
+					msg.ToolCalls = append(msg.ToolCalls, chatToolCall{
+						ID:   p.ID,
+						Type: "function",
+						Function: chatFunctionCall{
+							Name:      p.FunctionCall.Name,
+							Arguments: p.FunctionCall.Arguments,
+						},
+					})
+				default:
+					return nil, fmt.Errorf("unsupported %T part within AI message", part)
+				}
+			}
+
+			if len(text) > 0 {
+				msg.Content = strings.Join(text, "")
+			}
+
+			result = append(result, msg)
+		case llms.ChatMessageTypeSystem, llms.ChatMessageTypeHuman, llms.ChatMessageTypeGeneric:
+			role := "user"
+			if m.Role == llms.ChatMessageTypeSystem {
+				role = "system"
+			}
+
+			content, err := toChatContent(m.Parts)
+			if err != nil {
+				return nil, fmt.Errorf("%s message: %w", m.Role, err)
+			}
+
+			result = append(result, chatMessage{Role: role, Content: content})
+		default:
+			return nil, fmt.Errorf("unsupported message role %q", m.Role)
+		}
+	}
+
+	return result, nil
+}
+
+// toChatContent returns a plain string when the message contains text only and a list of content parts otherwise.
+func toChatContent(parts []llms.ContentPart) (any, error) {
+	text := make([]string, 0, len(parts))
+	result := make([]chatContentPart, 0, len(parts))
+	textOnly := true
+
+	for _, part := range parts {
+		switch p := part.(type) {
+		case llms.TextContent:
+			text = append(text, p.Text)
+			result = append(result, chatContentPart{Type: "text", Text: p.Text})
+		case llms.ImageURLContent:
+			textOnly = false
+			result = append(result, chatContentPart{
+				Type:     "image_url",
+				ImageURL: &chatImageURL{URL: p.URL, Detail: p.Detail},
+			})
+		case llms.BinaryContent:
+			textOnly = false
+
+			data := base64.StdEncoding.EncodeToString(p.Data)
+
+			switch {
+			case strings.HasPrefix(p.MIMEType, "image/"):
+				result = append(result, chatContentPart{
+					Type:     "image_url",
+					ImageURL: &chatImageURL{URL: fmt.Sprintf("data:%s;base64,%s", p.MIMEType, data)},
+				})
+			case strings.HasPrefix(p.MIMEType, "audio/"):
+				format := strings.TrimPrefix(strings.TrimPrefix(p.MIMEType, "audio/"), "x-")
+				if format == "mpeg" {
+					format = "mp3"
+				}
+
+				result = append(result, chatContentPart{
+					Type:       "input_audio",
+					InputAudio: &chatInputAudio{Data: data, Format: format},
+				})
+			default:
+				return nil, fmt.Errorf("unsupported binary content type %q", p.MIMEType)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported content part type %T", part)
+		}
+	}
+
+	if textOnly {
+		return strings.Join(text, "\n"), nil
+	}
+
+	return result, nil
+}
@@ -0,0 +1,55 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestToChatContent(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		parts  []llms.ContentPart
+		expect any
+	}{
+		{
+			name:   "text",
+			parts:  []llms.ContentPart{llms.TextPart("Hello"), llms.TextPart("there")},
+			expect: "Hello\nthere",
+		},
+		{
+			name:  "audio",
+			parts: []llms.ContentPart{llms.BinaryPart("audio/wav", []byte("wav"))},
+			expect: []chatContentPart{
+				{Type: "input_audio", InputAudio: &chatInputAudio{Data: "d2F2", Format: "wav"}},
+			},
+		},
+		{
+			name:  "mp3 audio",
+			parts: []llms.ContentPart{llms.BinaryPart("audio/mpeg", []byte("mp3"))},
+			expect: []chatContentPart{
+				{Type: "input_audio", InputAudio: &chatInputAudio{Data: "bXAz", Format: "mp3"}},
+			},
+		},
+		{
+			name:  "text and image",
+			parts: []llms.ContentPart{llms.TextPart("What is this?"), llms.BinaryPart("image/png", []byte("png"))},
+			expect: []chatContentPart{
+				{Type: "text", Text: "What is this?"},
+				{Type: "image_url", ImageURL: &chatImageURL{URL: "data:image/png;base64,cG5n"}},
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := toChatContent(c.parts)
+			require.NoError(t, err)
+			require.Equal(t, c.expect, actual)
+		})
+	}
+}
+
+func TestToChatContentUnsupportedBinary(t *testing.T) {
+	_, err := toChatContent([]llms.ContentPart{llms.BinaryPart("application/pdf", []byte("pdf"))})
+	require.Error(t, err)
+}
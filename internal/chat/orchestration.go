@@ -0,0 +1,135 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/tools"
+)
+
+// AgentOrchestration specifies how multiple agents that were called within the same LLM response are run.
+type AgentOrchestration string
+
+const (
+	// AgentOrchestrationSingle runs the first agent only, skipping the others.
+	AgentOrchestrationSingle AgentOrchestration = "single"
+	// AgentOrchestrationSequential runs the agents one after another.
+	AgentOrchestrationSequential AgentOrchestration = "sequential"
+	// AgentOrchestrationParallel runs the agents concurrently while their responses are still spoken in call order.
+	AgentOrchestrationParallel AgentOrchestration = "parallel"
+)
+
+// ParseAgentOrchestration returns the agent orchestration mode with the given name.
+func ParseAgentOrchestration(mode string) (AgentOrchestration, error) {
+	switch m := AgentOrchestration(mode); m {
+	case "":
+		return AgentOrchestrationSequential, nil
+	case AgentOrchestrationSingle, AgentOrchestrationSequential, AgentOrchestrationParallel:
+		return m, nil
+	default:
+		return m, fmt.Errorf("unsupported agent orchestration mode %q", mode)
+	}
+}
+
+// delegatingTool is a tool that answers the user directly, writing its response into the given channel.
+type delegatingTool interface {
+	tools.Tool
+	callDelegated(ctx context.Context, arguments string, ch chan<- ResponseChunk) error
+}
+
+func isDelegatingTool(t tools.Tool) bool {
+	_, ok := t.(delegatingTool)
+	return ok
+}
+
+// delegatedOutputBufferSize is the amount of response chunks an agent can emit before it blocks
+// while the responses of the agents that were called before are still forwarded.
+const delegatedOutputBufferSize = 1000
+
+// runDelegatedToolCalls runs the calls of tools that answer the user directly, e.g. agents.
+// Their responses are forwarded to the user in call order and recorded as tool results,
+// letting the main LLM know what the user was told already.
+func (c *LLM) runDelegatedToolCalls(ctx context.Context, executions []*toolCallExecution, ch chan<- ResponseChunk) {
+	delegated := make([]*toolCallExecution, 0, len(executions))
+
+	for _, e := range executions {
+		if !e.Done && isDelegatingTool(e.Tool) {
+			if len(delegated) > 0 && c.AgentOrchestration == AgentOrchestrationSingle {
+				e.Result = "Skipped since another agent answered the user already."
+				e.Done = true
+
+				continue
+			}
+
+			delegated = append(delegated, e)
+		}
+	}
+
+	if len(delegated) == 0 {
+		return
+	}
+
+	outputs := make([]chan ResponseChunk, len(delegated))
+	responses := make([]string, len(delegated))
+	forwarded := make(chan struct{})
+
+	for i := range outputs {
+		outputs[i] = make(chan ResponseChunk, delegatedOutputBufferSize)
+	}
+
+	go func() {
+		defer close(forwarded)
+
+		for i, output := range outputs {
+			text := strings.Builder{}
+
+			for msg := range output {
+				ch <- msg
+
+				if msg.Type == model.MessageTypeChunk && !msg.UserOnly {
+					text.WriteString(msg.Text)
+				}
+			}
+
+			responses[i] = strings.TrimSpace(text.String())
+		}
+	}()
+
+	run := func(i int, e *toolCallExecution) {
+		defer close(outputs[i])
+
+		e.Err = e.Tool.(delegatingTool).callDelegated(ctx, e.Call.FunctionCall.Arguments, outputs[i])
+		e.Done = true
+		e.Delegated = e.Err == nil
+	}
+
+	if c.AgentOrchestration == AgentOrchestrationParallel {
+		wg := &sync.WaitGroup{}
+
+		for i, e := range delegated {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				run(i, e)
+			}()
+		}
+
+		wg.Wait()
+	} else {
+		for i, e := range delegated {
+			run(i, e)
+		}
+	}
+
+	<-forwarded
+
+	for i, e := range delegated {
+		if e.Delegated {
+			e.Result = fmt.Sprintf("The %s tool answered the user directly: %q", e.Call.FunctionCall.Name, responses[i])
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/tools"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+type fakeAgentTool struct {
+	fakeTool
+}
+
+func (t *fakeAgentTool) callDelegated(ctx context.Context, _ string, ch chan<- ResponseChunk) error {
+	for i := 1; i <= 2; i++ {
+		select {
+		case <-time.After(t.Duration / 2):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		ch <- ResponseChunk{Type: model.MessageTypeChunk, Text: fmt.Sprintf("%s %d. ", t.Name, i)}
+	}
+
+	return nil
+}
+
+func TestHandleToolCallsWithMultipleAgents(t *testing.T) {
+	for _, c := range []struct {
+		mode           AgentOrchestration
+		expectResponse []string
+		maxDuration    time.Duration
+	}{
+		{
+			mode:           AgentOrchestrationSingle,
+			expectResponse: []string{"music 1. ", "music 2. "},
+			maxDuration:    250 * time.Millisecond,
+		},
+		{
+			mode:           AgentOrchestrationSequential,
+			expectResponse: []string{"music 1. ", "music 2. ", "news 1. ", "news 2. "},
+			maxDuration:    450 * time.Millisecond,
+		},
+		{
+			mode:           AgentOrchestrationParallel,
+			expectResponse: []string{"music 1. ", "music 2. ", "news 1. ", "news 2. "},
+			maxDuration:    250 * time.Millisecond,
+		},
+	} {
+		t.Run(string(c.mode), func(t *testing.T) {
+			testee := &LLM{AgentOrchestration: c.mode}
+			fns := tools.NewCallLoopPreventingProvider([]tools.Tool{
+				&fakeAgentTool{fakeTool{Name: "music", Duration: 200 * time.Millisecond}},
+				&fakeAgentTool{fakeTool{Name: "news", Duration: 100 * time.Millisecond}},
+			}, tools.LoopPolicy{})
+			conv := model.NewConversation("fake prompt", 0)
+			reqNum := conv.AddUserRequest(llms.TextPart("Turn the music down and tell me the news"))
+			ch := make(chan ResponseChunk, 20)
+			calls := []llms.ToolCall{
+				{ID: "music-call", Type: "function", FunctionCall: &llms.FunctionCall{Name: "music", Arguments: "{}"}},
+				{ID: "news-call", Type: "function", FunctionCall: &llms.FunctionCall{Name: "news", Arguments: "{}"}},
+			}
+
+			start := time.Now()
+			err := testee.handleToolCalls(context.Background(), calls, reqNum, fns, conv, ch)
+			duration := time.Since(start)
+
+			require.NoError(t, err)
+			require.Less(t, duration, c.maxDuration, "duration")
+
+			close(ch)
+
+			response := []string{}
+			for msg := range ch {
+				if !msg.UserOnly {
+					response = append(response, msg.Text)
+				}
+			}
+
+			require.Equal(t, c.expectResponse, response, "response")
+
+			msgs := conv.RequestMessages()
+			require.Len(t, msgs, 5, "messages")
+			require.Contains(t, model.FormatMessage(msgs[2]), "answered the user directly", "music result")
+			require.Contains(t, model.FormatMessage(msgs[2]), "music 1. music 2.", "music result")
+		})
+	}
+}
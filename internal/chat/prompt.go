@@ -0,0 +1,43 @@
+package chat
+
+import (
+	"context"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/tools"
+)
+
+// PromptData provides the request-specific values a prompt is rendered with.
+type PromptData struct {
+	// Locale is the locale provided by the client, e.g. en-US (empty when unknown).
+	Locale string
+	// Tools are the names of the tools that are available to answer the request.
+	Tools []string
+}
+
+// Prompt renders a prompt for a request.
+type Prompt func(data PromptData) string
+
+func (p Prompt) render(data PromptData) string {
+	if p == nil {
+		return ""
+	}
+
+	return p(data)
+}
+
+func toolNames(tools []tools.Tool) []string {
+	names := make([]string, len(tools))
+	for i, t := range tools {
+		names[i] = t.Definition().Name
+	}
+
+	return names
+}
+
+type localeKey struct{}
+
+// requestLocale returns the locale of the user request that is processed within the given context.
+func requestLocale(ctx context.Context) string {
+	locale, _ := ctx.Value(localeKey{}).(string)
+	return locale
+}
@@ -0,0 +1,186 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ThinkingMode specifies whether and how much a reasoning model should think before responding.
+type ThinkingMode string
+
+const (
+	// ThinkingModeDefault does not specify a thinking mode, leaving it up to the model.
+	ThinkingModeDefault ThinkingMode = ""
+	ThinkingModeNone    ThinkingMode = "none"
+	ThinkingModeLow     ThinkingMode = "low"
+	ThinkingModeMedium  ThinkingMode = "medium"
+	ThinkingModeHigh    ThinkingMode = "high"
+)
+
+// ParseThinkingMode returns the thinking mode with the given name.
+func ParseThinkingMode(mode string) (ThinkingMode, error) {
+	switch m := ThinkingMode(mode); m {
+	case ThinkingModeDefault, ThinkingModeNone, ThinkingModeLow, ThinkingModeMedium, ThinkingModeHigh:
+		return m, nil
+	default:
+		return m, fmt.Errorf("unsupported thinking mode %q", mode)
+	}
+}
+
+// thinkingMode returns the LLM's thinking mode, falling back to the backend's one and then to the default one.
+func (c *LLM) thinkingMode(backend *Backend) ThinkingMode {
+	if c.ThinkingMode != ThinkingModeDefault {
+		return c.ThinkingMode
+	}
+
+	if backend.ThinkingMode != ThinkingModeDefault {
+		return backend.ThinkingMode
+	}
+
+	return c.DefaultThinkingMode
+}
+
+// apply sets the request parameters that enable or disable thinking.
+// Disabling it is done via the chat template since models like qwen3 don't support the reasoning_effort parameter.
+func (m ThinkingMode) apply(req *chatCompletionRequest) {
+	switch m {
+	case ThinkingModeDefault:
+	case ThinkingModeNone:
+		req.ChatTemplateKwargs = map[string]any{"enable_thinking": false}
+	default:
+		req.ChatTemplateKwargs = map[string]any{"enable_thinking": true}
+		req.ReasoningEffort = string(m)
+	}
+}
+
+// callOption returns the call option that sets the thinking mode.
+// The thinking mode is left unset when the default mode is specified.
+func (m ThinkingMode) callOption() llms.CallOption {
+	switch m {
+	case ThinkingModeNone:
+		return llms.WithThinkingMode(llms.ThinkingModeNone)
+	case ThinkingModeLow:
+		return llms.WithThinkingMode(llms.ThinkingModeLow)
+	case ThinkingModeMedium:
+		return llms.WithThinkingMode(llms.ThinkingModeMedium)
+	case ThinkingModeHigh:
+		return llms.WithThinkingMode(llms.ThinkingModeHigh)
+	default:
+		return func(*llms.CallOptions) {}
+	}
+}
+
+var reasoningTags = [][2]string{
+	{"<think>", "</think>"},
+	{"<thinking>", "</thinking>"},
+}
+
+// reasoningFilter separates reasoning enclosed in think tags from the streamed response text.
+// Tags may be split across chunks: a chunk's suffix that may be the beginning of a tag is held back until the next chunk.
+type reasoningFilter struct {
+	closeTag  string
+	pending   string
+	reasoning strings.Builder
+}
+
+// Write returns the part of the chunk that does not belong to the model's reasoning.
+func (f *reasoningFilter) Write(chunk string) string {
+	s := f.pending + chunk
+	f.pending = ""
+	text := strings.Builder{}
+
+	for s != "" {
+		if f.closeTag != "" {
+			if i := strings.Index(s, f.closeTag); i >= 0 {
+				f.reasoning.WriteString(s[:i])
+				s = s[i+len(f.closeTag):]
+				f.closeTag = ""
+
+				continue
+			}
+
+			n := partialTagSuffixLen(s, []string{f.closeTag})
+			f.reasoning.WriteString(s[:len(s)-n])
+			f.pending = s[len(s)-n:]
+
+			break
+		}
+
+		start, tag := -1, [2]string{}
+
+		for _, t := range reasoningTags {
+			if i := strings.Index(s, t[0]); i >= 0 && (start < 0 || i < start) {
+				start, tag = i, t
+			}
+		}
+
+		if start >= 0 {
+			text.WriteString(s[:start])
+			s = s[start+len(tag[0]):]
+			f.closeTag = tag[1]
+
+			if f.reasoning.Len() > 0 {
+				f.reasoning.WriteString("\n")
+			}
+
+			continue
+		}
+
+		openTags := make([]string, len(reasoningTags))
+		for i, t := range reasoningTags {
+			openTags[i] = t[0]
+		}
+
+		n := partialTagSuffixLen(s, openTags)
+		text.WriteString(s[:len(s)-n])
+		f.pending = s[len(s)-n:]
+
+		break
+	}
+
+	return text.String()
+}
+
+// Flush returns the held back text at the end of the stream.
+func (f *reasoningFilter) Flush() string {
+	s := f.pending
+	f.pending = ""
+
+	if f.closeTag != "" {
+		f.reasoning.WriteString(s) // unterminated reasoning
+		return ""
+	}
+
+	return s
+}
+
+// Reasoning returns the reasoning that was filtered out of the response so far.
+func (f *reasoningFilter) Reasoning() string {
+	return strings.TrimSpace(f.reasoning.String())
+}
+
+// partialTagSuffixLen returns the length of the longest suffix of s that is a prefix of one of the given tags.
+func partialTagSuffixLen(s string, tags []string) int {
+	longest := 0
+
+	for _, tag := range tags {
+		for n := min(len(tag)-1, len(s)); n > longest; n-- {
+			if strings.HasSuffix(s, tag[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+
+	return longest
+}
+
+// stripReasoning removes the reasoning enclosed in think tags from a complete response.
+func stripReasoning(s string) string {
+	f := &reasoningFilter{}
+	text := f.Write(s) + f.Flush()
+
+	return strings.TrimSpace(text)
+}
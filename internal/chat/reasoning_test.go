@@ -0,0 +1,97 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestReasoningFilter(t *testing.T) {
+	for _, c := range []struct {
+		name            string
+		chunks          []string
+		expectText      string
+		expectReasoning string
+	}{
+		{
+			name:       "no reasoning",
+			chunks:     []string{"Hello ", "world. 1 < 2"},
+			expectText: "Hello world. 1 < 2",
+		},
+		{
+			name:            "reasoning within a single chunk",
+			chunks:          []string{"<think>The user greets me.</think>Hello!"},
+			expectText:      "Hello!",
+			expectReasoning: "The user greets me.",
+		},
+		{
+			name:            "tags split across chunks",
+			chunks:          []string{"<", "thi", "nk>The user ", "greets me.</th", "ink>", "\n\nHello", "!"},
+			expectText:      "\n\nHello!",
+			expectReasoning: "The user greets me.",
+		},
+		{
+			name:            "thinking tag",
+			chunks:          []string{"Sure. <thinking>Which city?</thinking> It is sunny."},
+			expectText:      "Sure.  It is sunny.",
+			expectReasoning: "Which city?",
+		},
+		{
+			name:            "unterminated reasoning",
+			chunks:          []string{"<think>Hmm", ", let me think</"},
+			expectReasoning: "Hmm, let me think</",
+		},
+		{
+			name:       "incomplete tag at the end",
+			chunks:     []string{"Hello <thi"},
+			expectText: "Hello <thi",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			testee := &reasoningFilter{}
+			text := strings.Builder{}
+
+			for _, chunk := range c.chunks {
+				text.WriteString(testee.Write(chunk))
+			}
+
+			text.WriteString(testee.Flush())
+
+			require.Equal(t, c.expectText, text.String(), "text")
+			require.Equal(t, c.expectReasoning, testee.Reasoning(), "reasoning")
+		})
+	}
+}
+
+func TestThinkingModePrecedence(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		llm     ThinkingMode
+		backend ThinkingMode
+		def     ThinkingMode
+		expect  ThinkingMode
+	}{
+		{name: "unspecified", expect: ThinkingModeDefault},
+		{name: "default", def: ThinkingModeNone, expect: ThinkingModeNone},
+		{name: "backend", backend: ThinkingModeLow, def: ThinkingModeNone, expect: ThinkingModeLow},
+		{name: "agent", llm: ThinkingModeHigh, backend: ThinkingModeLow, def: ThinkingModeNone, expect: ThinkingModeHigh},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			testee := &LLM{ThinkingMode: c.llm, DefaultThinkingMode: c.def}
+
+			require.Equal(t, c.expect, testee.thinkingMode(&Backend{ThinkingMode: c.backend}))
+		})
+	}
+}
+
+func TestThinkingModeCallOption(t *testing.T) {
+	opts := llms.CallOptions{}
+	ThinkingModeDefault.callOption()(&opts)
+	require.Nil(t, llms.GetThinkingConfig(&opts), "default mode")
+
+	ThinkingModeNone.callOption()(&opts)
+	require.NotNil(t, llms.GetThinkingConfig(&opts), "none mode")
+	require.Equal(t, llms.ThinkingModeNone, llms.GetThinkingConfig(&opts).Mode, "none mode")
+}
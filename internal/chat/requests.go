@@ -2,6 +2,7 @@ package chat
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 
 	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
@@ -11,7 +12,31 @@ import (
 
 type Message = model.AudioMessage
 
+// AudioInputMode specifies how voice requests are passed to the chat model.
+type AudioInputMode string
+
+const (
+	// AudioInputModeTranscribe sends the transcription of a voice request to the chat model.
+	AudioInputModeTranscribe AudioInputMode = "transcribe"
+	// AudioInputModeDirect sends the recorded audio to audio-capable chat models directly.
+	// This reduces the latency and preserves the tone of the user's voice.
+	AudioInputModeDirect AudioInputMode = "direct"
+)
+
+// ParseAudioInputMode returns the audio input mode with the given name.
+func ParseAudioInputMode(mode string) (AudioInputMode, error) {
+	switch m := AudioInputMode(mode); m {
+	case "":
+		return AudioInputModeTranscribe, nil
+	case AudioInputModeTranscribe, AudioInputModeDirect:
+		return m, nil
+	default:
+		return m, fmt.Errorf("unsupported audio input mode %q", mode)
+	}
+}
+
 type Requester struct {
+	AudioInputMode AudioInputMode
 }
 
 func (r *Requester) AddUserRequestsToConversation(ctx context.Context, requests <-chan Message, conv *model.Conversation) (<-chan ChatCompletionRequest, <-chan soundgen.Request) {
@@ -24,7 +49,15 @@ func (r *Requester) AddUserRequestsToConversation(ctx context.Context, requests
 
 		for req := range requests {
 			var msg llms.ContentPart
-			if len(req.Text) > 0 {
+			transcription := ""
+			if r.AudioInputMode == AudioInputModeDirect && len(req.WaveData) > 0 {
+				if req.Text != "" {
+					slog.Debug(fmt.Sprintf("sending audio instead of transcription: %s", req.Text))
+				}
+
+				msg = llms.BinaryPart("audio/wav", req.WaveData)
+				transcription = req.Text
+			} else if len(req.Text) > 0 {
 				msg = llms.TextPart(req.Text + " ")
 			} else if len(req.WaveData) > 0 {
 				msg = llms.BinaryPart("audio/wav", req.WaveData)
@@ -34,10 +67,18 @@ func (r *Requester) AddUserRequestsToConversation(ctx context.Context, requests
 				continue
 			}
 
-			reqNum := conv.AddUserRequest(msg)
+			parts := make([]llms.ContentPart, 0, len(req.Images)+1)
+			parts = append(parts, msg)
+
+			for _, img := range req.Images {
+				parts = append(parts, llms.BinaryPart(img.MIMEType, img.Data))
+			}
+
+			reqNum := conv.AddVoiceRequest(transcription, parts...)
 
 			ch <- ChatCompletionRequest{
 				RequestNum: reqNum,
+				Locale:     req.Locale,
 			}
 			notifications <- soundgen.Request{
 				RequestNum: reqNum,
@@ -0,0 +1,45 @@
+package chat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestRequesterAudioInputMode(t *testing.T) {
+	for _, c := range []struct {
+		mode                AudioInputMode
+		expect              llms.ContentPart
+		expectTranscription string
+	}{
+		{AudioInputModeTranscribe, llms.TextPart("Computer, how are you? "), ""},
+		{AudioInputModeDirect, llms.BinaryPart("audio/wav", []byte("fake wav")), "Computer, how are you?"},
+	} {
+		t.Run(string(c.mode), func(t *testing.T) {
+			testee := &Requester{AudioInputMode: c.mode}
+			conv := model.NewConversation("You are a helpful assistant.", 0)
+			requests := make(chan Message, 1)
+			requests <- Message{
+				Message:  model.Message{Text: "Computer, how are you?", Locale: "en-US"},
+				WaveData: []byte("fake wav"),
+			}
+			close(requests)
+
+			completionRequests, notifications := testee.AddUserRequestsToConversation(context.Background(), requests, conv)
+
+			req := <-completionRequests
+			<-notifications
+
+			require.Equal(t, int64(1), req.RequestNum, "request number")
+			require.Equal(t, "en-US", req.Locale, "locale")
+
+			messages := conv.Messages()
+			require.Len(t, messages, 2, "messages")
+			require.Equal(t, []llms.ContentPart{c.expect}, messages[1].Parts, "user request parts")
+			require.Equal(t, c.expectTranscription, conv.State().Messages[1].Transcription, "transcription")
+		})
+	}
+}
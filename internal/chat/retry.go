@@ -0,0 +1,168 @@
+package chat
+
+import (
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// RetryPolicy specifies how failed chat completion requests are retried.
+// Zero values are replaced with defaults.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func (p RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return 4
+	}
+
+	return p.MaxAttempts
+}
+
+// backoff returns the exponentially growing delay before the next attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = 500 * time.Millisecond
+	}
+
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 8 * time.Second
+	}
+
+	backoff := initial
+
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, maxBackoff)
+}
+
+type ErrorKind string
+
+const (
+	ErrorKindUnavailable    ErrorKind = "unavailable"
+	ErrorKindRateLimit      ErrorKind = "rate_limit"
+	ErrorKindContextLength  ErrorKind = "context_length"
+	ErrorKindAuthentication ErrorKind = "authentication"
+	ErrorKindInvalidRequest ErrorKind = "invalid_request"
+	ErrorKindUnknown        ErrorKind = "unknown"
+)
+
+// CompletionError is a classified chat completion error.
+type CompletionError struct {
+	Kind       ErrorKind
+	StatusCode int
+	Err        error
+}
+
+func (e *CompletionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CompletionError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable returns true if the chat completion request that failed with the given error may succeed when repeated.
+func IsRetryable(err error) bool {
+	var e *CompletionError
+	if !errors.As(err, &e) {
+		return true
+	}
+
+	switch e.Kind {
+	case ErrorKindContextLength, ErrorKindAuthentication, ErrorKindInvalidRequest:
+		return false
+	default:
+		return true
+	}
+}
+
+// UserErrorMessage returns an error message that can be spoken to the user.
+func UserErrorMessage(err error) string {
+	var e *CompletionError
+	if !errors.As(err, &e) {
+		e = classifyError(err).(*CompletionError)
+	}
+
+	switch e.Kind {
+	case ErrorKindUnavailable:
+		return "Sorry, I cannot reach my language model server at the moment. Please try again later."
+	case ErrorKindRateLimit:
+		return "Sorry, my language model server is busy at the moment. Please try again in a minute."
+	case ErrorKindContextLength:
+		return "Sorry, our conversation got too long for me to process. Please reset the conversation or ask a shorter question."
+	case ErrorKindAuthentication:
+		return "Sorry, I am not allowed to use my language model server. Please check the API key."
+	default:
+		return "Sorry, something went wrong while generating my response."
+	}
+}
+
+var statusCodeRegex = regexp.MustCompile(`status code: (\d{3})`)
+
+var contextLengthPatterns = []string{
+	"context length",
+	"maximum context",
+	"context size",
+	"context window",
+	"too many tokens",
+	"exceeds the available context",
+}
+
+func classifyError(err error) error {
+	var e *CompletionError
+	if errors.As(err, &e) {
+		return err
+	}
+
+	e = &CompletionError{Kind: ErrorKindUnknown, Err: err}
+	msg := strings.ToLower(err.Error())
+
+	if m := statusCodeRegex.FindStringSubmatch(msg); len(m) == 2 {
+		e.StatusCode, _ = strconv.Atoi(m[1])
+	}
+
+	var netErr net.Error
+
+	switch {
+	case containsAny(msg, contextLengthPatterns) || llms.IsTokenLimitError(err):
+		e.Kind = ErrorKindContextLength
+	case e.StatusCode == 401 || e.StatusCode == 403 || llms.IsAuthenticationError(err):
+		e.Kind = ErrorKindAuthentication
+	case e.StatusCode == 429 || llms.IsRateLimitError(err):
+		e.Kind = ErrorKindRateLimit
+	case e.StatusCode == 408 || e.StatusCode >= 500:
+		e.Kind = ErrorKindUnavailable
+	case e.StatusCode >= 400:
+		e.Kind = ErrorKindInvalidRequest
+	case errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr):
+		e.Kind = ErrorKindUnavailable
+	}
+
+	return e
+}
+
+func containsAny(s string, patterns []string) bool {
+	for _, p := range patterns {
+		if strings.Contains(s, p) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,86 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		err       error
+		kind      ErrorKind
+		retryable bool
+	}{
+		{
+			name:      "connection refused",
+			err:       fmt.Errorf("post: %w", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}),
+			kind:      ErrorKindUnavailable,
+			retryable: true,
+		},
+		{
+			name:      "internal server error",
+			err:       errors.New("API returned unexpected status code: 500: internal error"),
+			kind:      ErrorKindUnavailable,
+			retryable: true,
+		},
+		{
+			name:      "rate limit",
+			err:       errors.New("API returned unexpected status code: 429"),
+			kind:      ErrorKindRateLimit,
+			retryable: true,
+		},
+		{
+			name:      "context length exceeded",
+			err:       errors.New("API returned unexpected status code: 400: the request exceeds the available context size"),
+			kind:      ErrorKindContextLength,
+			retryable: false,
+		},
+		{
+			name:      "unauthorized",
+			err:       errors.New("API returned unexpected status code: 401: invalid api key"),
+			kind:      ErrorKindAuthentication,
+			retryable: false,
+		},
+		{
+			name:      "bad request",
+			err:       errors.New("API returned unexpected status code: 400: invalid model"),
+			kind:      ErrorKindInvalidRequest,
+			retryable: false,
+		},
+		{
+			name:      "unknown",
+			err:       errors.New("something unexpected"),
+			kind:      ErrorKindUnknown,
+			retryable: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := classifyError(tc.err)
+
+			var e *CompletionError
+			require.True(t, errors.As(err, &e), "should return CompletionError")
+			require.Equal(t, tc.kind, e.Kind, "kind")
+			require.Equal(t, tc.retryable, IsRetryable(err), "retryable")
+			require.ErrorIs(t, err, tc.err, "should wrap original error")
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	testee := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	actual := make([]time.Duration, 0, 5)
+	for attempt := 1; attempt <= 5; attempt++ {
+		actual = append(actual, testee.backoff(attempt))
+	}
+
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, actual)
+}
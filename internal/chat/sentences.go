@@ -19,6 +19,8 @@ func ChunksToSentences(chunks <-chan ResponseChunk) <-chan ResponseChunk {
 
 		var buf bytes.Buffer
 
+		voice := ""
+
 		for chunk := range chunks {
 			switch chunk.Type {
 			case model.MessageTypeChunk:
@@ -27,6 +29,22 @@ func ChunksToSentences(chunks <-chan ResponseChunk) <-chan ResponseChunk {
 					continue
 				}
 
+				if chunk.Voice != voice {
+					// Don't merge the responses of agents that speak with different voices.
+					if buf.Len() > 0 {
+						ch <- ResponseChunk{
+							Type:       model.MessageTypeChunk,
+							RequestNum: chunk.RequestNum,
+							Text:       buf.String(),
+							Voice:      voice,
+						}
+
+						buf.Reset()
+					}
+
+					voice = chunk.Voice
+				}
+
 				buf.WriteString(chunk.Text)
 
 				if sentences := splitIntoSentences(buf.String()); len(sentences) > 1 {
@@ -35,6 +53,7 @@ func ChunksToSentences(chunks <-chan ResponseChunk) <-chan ResponseChunk {
 							Type:       model.MessageTypeChunk,
 							RequestNum: chunk.RequestNum,
 							Text:       sentence,
+							Voice:      voice,
 						}
 					}
 
@@ -46,6 +65,7 @@ func ChunksToSentences(chunks <-chan ResponseChunk) <-chan ResponseChunk {
 							Type:       model.MessageTypeChunk,
 							RequestNum: chunk.RequestNum,
 							Text:       lastSentencePrefix,
+							Voice:      voice,
 						}
 					} else {
 						buf.WriteString(lastSentencePrefix)
@@ -57,6 +77,7 @@ func ChunksToSentences(chunks <-chan ResponseChunk) <-chan ResponseChunk {
 						Type:       model.MessageTypeChunk,
 						RequestNum: chunk.RequestNum,
 						Text:       strings.TrimSuffix(buf.String(), "</s>"),
+						Voice:      voice,
 					}
 				}
 
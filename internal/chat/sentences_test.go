@@ -245,3 +245,36 @@ func TestChunksToSentences(t *testing.T) {
 		})
 	}
 }
+
+func TestChunksToSentencesWithVoices(t *testing.T) {
+	ch := make(chan ResponseChunk, 10)
+
+	for _, c := range []ResponseChunk{
+		{Text: "Let me ask the", Voice: ""},
+		{Text: " research agent", Voice: ""},
+		{Text: "Berlin is the capital.", Voice: "alloy"},
+		{Text: " It is", Voice: "alloy"},
+		{Text: " big", Voice: "alloy"},
+	} {
+		c.Type = model.MessageTypeChunk
+		c.RequestNum = 1
+		ch <- c
+	}
+
+	ch <- ResponseChunk{Type: model.MessageTypeEnd, RequestNum: 1}
+
+	close(ch)
+
+	sentences := []ResponseChunk{}
+	for s := range ChunksToSentences(ch) {
+		if s.Type == model.MessageTypeChunk {
+			sentences = append(sentences, ResponseChunk{Text: s.Text, Voice: s.Voice})
+		}
+	}
+
+	require.Equal(t, []ResponseChunk{
+		{Text: "Let me ask the research agent"},
+		{Text: "Berlin is the capital. ", Voice: "alloy"},
+		{Text: "It is big", Voice: "alloy"},
+	}, sentences)
+}
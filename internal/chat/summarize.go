@@ -0,0 +1,57 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+	"github.com/tmc/langchaingo/llms"
+)
+
+const summaryPrompt = `Summarize the following conversation between a user and an AI assistant concisely.
+Keep facts, names, numbers, decisions and open questions the assistant may need to answer follow-up requests.
+Respond with the summary only.`
+
+// Summarize summarizes the given messages using the chat model, extending the previous summary.
+func (c *LLM) Summarize(ctx context.Context, summary string, messages []llms.MessageContent) (string, error) {
+	backend, err := c.selectBackend(nil)
+	if err != nil {
+		return "", err
+	}
+
+	llm, err := backend.client()
+	if err != nil {
+		return "", err
+	}
+
+	lines := make([]string, 0, len(messages)+1)
+	if summary != "" {
+		lines = append(lines, fmt.Sprintf("Summary of the earlier conversation: %s", summary))
+	}
+
+	for _, msg := range messages {
+		lines = append(lines, model.FormatMessage(msg))
+	}
+
+	resp, err := llm.GenerateContent(ctx,
+		[]llms.MessageContent{
+			llms.TextParts(llms.ChatMessageTypeSystem, summaryPrompt),
+			llms.TextParts(llms.ChatMessageTypeHuman, strings.Join(lines, "\n")),
+		},
+		llms.WithTemperature(0),
+		c.thinkingMode(backend).callOption(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("summarize conversation: %w", err)
+	}
+
+	c.recordUsage(0, summarizerAgentName, backend, usageFromResponse(resp))
+
+	if len(resp.Choices) == 0 || stripReasoning(resp.Choices[0].Content) == "" {
+		return "", errors.New("summarize conversation: empty response")
+	}
+
+	return stripReasoning(resp.Choices[0].Content), nil
+}
@@ -0,0 +1,207 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ToolCallMode specifies how tool calls are requested from the LLM and parsed from its response.
+type ToolCallMode string
+
+const (
+	// ToolCallModeNative uses the tools API and assembles streamed tool call deltas.
+	ToolCallModeNative ToolCallMode = "native"
+	// ToolCallModeLegacy uses langchaingo's function calling support and detects tool calls within the streamed chunks.
+	ToolCallModeLegacy ToolCallMode = "legacy"
+)
+
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceRequired = "required"
+	ToolChoiceNone     = "none"
+)
+
+// toolChoice returns the tool_choice request parameter for the given turn.
+// A named tool is only enforced during the first turn since the LLM would otherwise call it forever.
+func (c *LLM) toolChoice(turn int) any {
+	switch c.ToolChoice {
+	case "", ToolChoiceAuto:
+		return nil
+	case ToolChoiceRequired, ToolChoiceNone:
+		return c.ToolChoice
+	default:
+		if turn > 1 {
+			return nil
+		}
+
+		return llms.ToolChoice{
+			Type:     "function",
+			Function: &llms.FunctionReference{Name: c.ToolChoice},
+		}
+	}
+}
+
+// toolCallDelta is a tool call fragment of a streamed chat completion response.
+type toolCallDelta struct {
+	Index    *int   `json:"index"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// toolCallAssembler merges streamed tool call deltas into complete tool calls.
+// Deltas are correlated by their index, falling back to the call ID.
+// Deltas without both continue the previous call.
+type toolCallAssembler struct {
+	calls []*toolCallBuilder
+}
+
+type toolCallBuilder struct {
+	Index     *int
+	ID        string
+	Type      string
+	Name      string
+	Arguments strings.Builder
+}
+
+func (a *toolCallAssembler) Add(deltas []toolCallDelta) {
+	for _, d := range deltas {
+		call := a.find(d)
+		if call == nil {
+			call = &toolCallBuilder{Index: d.Index}
+			a.calls = append(a.calls, call)
+		}
+
+		if call.ID == "" {
+			call.ID = d.ID
+		}
+
+		if call.Type == "" {
+			call.Type = d.Type
+		}
+
+		switch {
+		case d.Function.Name == "":
+		case d.ID != "" && call.Name != "":
+			// Deltas that repeat the call ID carry the complete name
+			call.Name = d.Function.Name
+		default:
+			call.Name += d.Function.Name
+		}
+
+		call.Arguments.WriteString(d.Function.Arguments)
+	}
+}
+
+func (a *toolCallAssembler) find(d toolCallDelta) *toolCallBuilder {
+	for _, call := range a.calls {
+		if d.Index != nil && call.Index != nil {
+			if *d.Index == *call.Index {
+				return call
+			}
+
+			continue
+		}
+
+		if d.ID != "" && d.ID == call.ID {
+			return call
+		}
+	}
+
+	if d.Index == nil && d.ID == "" && len(a.calls) > 0 {
+		return a.calls[len(a.calls)-1]
+	}
+
+	return nil
+}
+
+// ToolCalls returns the assembled tool calls in the order they were started.
+func (a *toolCallAssembler) ToolCalls() []llms.ToolCall {
+	calls := make([]llms.ToolCall, 0, len(a.calls))
+
+	for i, call := range a.calls {
+		if call.Name == "" {
+			continue
+		}
+
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", i)
+		}
+
+		args := call.Arguments.String()
+		if strings.TrimSpace(args) == "" {
+			args = "{}"
+		}
+
+		calls = append(calls, llms.ToolCall{
+			ID:   id,
+			Type: "function",
+			FunctionCall: &llms.FunctionCall{
+				Name:      call.Name,
+				Arguments: args,
+			},
+		})
+	}
+
+	return calls
+}
+
+// mergeToolCalls merges the tool calls detected within the streamed chunks in legacy mode.
+func mergeToolCalls(calls []aiToolCall) []aiToolCall {
+	callMap := make(map[string]aiToolCall, len(calls))
+	ids := make([]string, 0, len(calls))
+	result := make([]aiToolCall, 0, len(calls))
+
+	for _, call := range calls {
+		if call.FunctionCall == nil {
+			continue
+		}
+
+		if lastCall, ok := callMap[call.ID]; ok {
+			if lastCall.FunctionCall.Name != "" {
+				call.FunctionCall.Name = lastCall.FunctionCall.Name
+			}
+			if lastCall.FunctionCall.Arguments != "" {
+				call.FunctionCall.Arguments = lastCall.FunctionCall.Arguments
+			}
+		} else {
+			ids = append(ids, call.ID)
+		}
+
+		callMap[call.ID] = call
+	}
+
+	for _, id := range ids {
+		result = append(result, callMap[id])
+	}
+
+	return result
+}
+
+type aiToolCall struct {
+	ID           string        `json:"id"`
+	Type         string        `type:"type"`
+	FunctionCall *functionCall `json:"function,omitempty"`
+}
+
+func (c *aiToolCall) ToolCall() llms.ToolCall {
+	return llms.ToolCall{
+		ID:   c.ID,
+		Type: c.Type,
+		FunctionCall: &llms.FunctionCall{
+			Name:      c.FunctionCall.Name,
+			Arguments: c.FunctionCall.Arguments,
+		},
+	}
+}
+
+type functionCall struct {
+	Name      string `json:"name"`
+	Arguments string `Json:"arguments"`
+}
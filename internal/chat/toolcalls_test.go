@@ -0,0 +1,154 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestToolCallAssembler(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		deltas string
+		expect []llms.ToolCall
+	}{
+		{
+			name: "interleaved calls by index",
+			deltas: `[
+				{"index":0,"id":"a","type":"function","function":{"name":"get_weather","arguments":""}},
+				{"index":1,"id":"b","type":"function","function":{"name":"get_news","arguments":"{\"top"}},
+				{"index":0,"function":{"arguments":"{\"city\":"}},
+				{"index":1,"function":{"arguments":"ic\":\"tech\"}"}},
+				{"index":0,"function":{"arguments":"\"Berlin\"}"}}
+			]`,
+			expect: []llms.ToolCall{
+				toolCall("a", "get_weather", `{"city":"Berlin"}`),
+				toolCall("b", "get_news", `{"topic":"tech"}`),
+			},
+		},
+		{
+			name: "calls by id with repeated name",
+			deltas: `[
+				{"id":"a","type":"function","function":{"name":"get_weather","arguments":"{\"city\""}},
+				{"id":"a","function":{"name":"get_weather","arguments":":\"Berlin\"}"}},
+				{"id":"b","type":"function","function":{"name":"get_news"}}
+			]`,
+			expect: []llms.ToolCall{
+				toolCall("a", "get_weather", `{"city":"Berlin"}`),
+				toolCall("b", "get_news", `{}`),
+			},
+		},
+		{
+			name: "name streamed in fragments",
+			deltas: `[
+				{"index":0,"id":"a","type":"function","function":{"name":"get"}},
+				{"index":0,"function":{"name":"_"}},
+				{"index":0,"function":{"name":"_"}},
+				{"index":0,"function":{"name":"weather","arguments":"{}"}},
+				{"index":1,"id":"b","type":"function","function":{"name":"go"}},
+				{"index":1,"function":{"name":"go"}}
+			]`,
+			expect: []llms.ToolCall{
+				toolCall("a", "get__weather", `{}`),
+				toolCall("b", "gogo", `{}`),
+			},
+		},
+		{
+			name: "anonymous deltas continue the previous call",
+			deltas: `[
+				{"id":"a","type":"function","function":{"name":"get_weather"}},
+				{"function":{"arguments":"{\"city\":"}},
+				{"function":{"arguments":"\"Berlin\"}"}}
+			]`,
+			expect: []llms.ToolCall{
+				toolCall("a", "get_weather", `{"city":"Berlin"}`),
+			},
+		},
+		{
+			name: "generate missing id",
+			deltas: `[
+				{"index":0,"function":{"name":"get_news","arguments":"{}"}}
+			]`,
+			expect: []llms.ToolCall{
+				toolCall("call_0", "get_news", `{}`),
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var deltas []toolCallDelta
+
+			err := json.Unmarshal([]byte(c.deltas), &deltas)
+			require.NoError(t, err)
+
+			testee := &toolCallAssembler{}
+
+			for _, d := range deltas {
+				testee.Add([]toolCallDelta{d})
+			}
+
+			require.Equal(t, c.expect, testee.ToolCalls())
+		})
+	}
+}
+
+func TestStreamChatCompletion(t *testing.T) {
+	var req chatCompletionRequest
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		require.NoError(t, err)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+
+		for _, chunk := range []string{
+			`{"choices":[{"delta":{"role":"assistant","content":"Let me "}}]}`,
+			`{"choices":[{"delta":{"content":"check."}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"a","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Berlin\"}"}}]},"finish_reason":"tool_calls"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":42,"completion_tokens":7,"total_tokens":49}}`,
+			`[DONE]`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+	}))
+	defer srv.Close()
+
+	testee := &LLM{ToolChoice: ToolChoiceRequired}
+	backend := &Backend{ServerURL: srv.URL, Model: "fake-model"}
+	chunks := []string{}
+
+	result, err := backend.streamChatCompletion(context.Background(), chatCompletionRequest{
+		Messages:   []chatMessage{{Role: "user", Content: "How is the weather?"}},
+		Tools:      []chatTool{{Type: "function", Function: llms.FunctionDefinition{Name: "get_weather"}}},
+		ToolChoice: testee.toolChoice(1),
+	}, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "fake-model", req.Model)
+	require.True(t, req.Stream)
+	require.Equal(t, "required", req.ToolChoice)
+	require.Equal(t, []string{"Let me ", "check."}, chunks)
+	require.Equal(t, &chatCompletionResult{
+		Content:      "Let me check.",
+		ToolCalls:    []llms.ToolCall{toolCall("a", "get_weather", `{"city":"Berlin"}`)},
+		FinishReason: "tool_calls",
+		Usage:        model.TokenUsage{PromptTokens: 42, CompletionTokens: 7, TotalTokens: 49},
+	}, result)
+}
+
+func toolCall(id, name, args string) llms.ToolCall {
+	return llms.ToolCall{
+		ID:           id,
+		Type:         "function",
+		FunctionCall: &llms.FunctionCall{Name: name, Arguments: args},
+	}
+}
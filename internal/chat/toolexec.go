@@ -0,0 +1,330 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-audio/wav"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/tools"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ToolExecutionPolicy specifies how the tool calls of an LLM response are executed.
+type ToolExecutionPolicy struct {
+	// MaxConcurrency is the maximum number of tools to run concurrently (defaults to 4).
+	MaxConcurrency int
+	// Timeout is the default tool call timeout (no timeout when 0).
+	Timeout time.Duration
+	// Timeouts overwrites the timeout of particular tools by name.
+	Timeouts map[string]time.Duration
+}
+
+func (p ToolExecutionPolicy) maxConcurrency() int {
+	if p.MaxConcurrency <= 0 {
+		return 4
+	}
+
+	return p.MaxConcurrency
+}
+
+func (p ToolExecutionPolicy) timeout(toolName string) time.Duration {
+	if t, ok := p.Timeouts[toolName]; ok {
+		return t
+	}
+
+	return p.Timeout
+}
+
+type toolCallExecution struct {
+	Call   llms.ToolCall
+	Tool   tools.Tool
+	Result string
+	// Attachments are images and audio clips returned by the tool.
+	Attachments []tools.Attachment
+	Err         error
+	Done        bool
+	// Delegated is true when the tool answered the user directly.
+	Delegated bool
+}
+
+// handleToolCalls runs the given tool calls concurrently and adds their results to the conversation in the original order.
+// Tools that answer the user directly (agents) are orchestrated separately since their output must not interleave.
+func (c *LLM) handleToolCalls(ctx context.Context, calls []llms.ToolCall, reqNum int64, fns *tools.CallLoopPreventingProvider, conv *model.Conversation, ch chan<- ResponseChunk) error {
+	executions := make([]*toolCallExecution, 0, len(calls))
+
+	var abortErr error
+
+	for _, call := range calls {
+		if conv.RequestCounter() > reqNum {
+			return nil // skip outdated request (user requested something else)
+		}
+
+		e, err := prepareToolCall(ctx, call, reqNum, fns, ch)
+		if err != nil {
+			// Re-request chat completion without the now banned tool
+			slog.Warn(fmt.Sprintf("failed to call tool %q: %s", call.FunctionCall.Name, err))
+
+			abortErr = &reconcileError{err}
+
+			break
+		}
+
+		executions = append(executions, e)
+	}
+
+	c.runToolCallsConcurrently(ctx, executions)
+	c.runDelegatedToolCalls(ctx, executions, ch)
+
+	delegated := false
+	answered := true
+	images := []llms.ContentPart{}
+
+	for _, e := range executions {
+		if conv.RequestCounter() > reqNum {
+			return nil // skip outdated request (user requested something else)
+		}
+
+		if !e.Done {
+			e.call(ctx)
+		}
+
+		if e.Err != nil {
+			msg := fmt.Sprintf("failed to call tool %q: %s", e.Call.FunctionCall.Name, e.Err)
+			e.Result = fmt.Sprintf("ERROR: %s", msg)
+
+			slog.Warn(msg)
+		}
+
+		delegated = delegated || e.Delegated
+		answered = answered && e.Delegated
+
+		images = append(images, handleToolAttachments(e, reqNum, ch)...)
+
+		conv.AddToolCallResponse(reqNum, e.Call, e.Result)
+	}
+
+	if len(images) > 0 {
+		conv.AddToolContent(reqNum, images...)
+	}
+
+	if delegated && (answered || c.AgentOrchestration == AgentOrchestrationSingle) {
+		return nil // the user was answered directly by agents
+	}
+
+	if abortErr != nil {
+		return abortErr
+	}
+
+	return &reconcileError{fmt.Errorf("needs reconciliation")}
+}
+
+// runToolCallsConcurrently calls the tools that don't answer the user directly concurrently.
+func (c *LLM) runToolCallsConcurrently(ctx context.Context, executions []*toolCallExecution) {
+	semaphore := make(chan struct{}, c.ToolExecution.maxConcurrency())
+	wg := &sync.WaitGroup{}
+
+	for _, e := range executions {
+		if e.Done || isDelegatingTool(e.Tool) {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				e.Err = ctx.Err()
+				e.Done = true
+				return
+			}
+
+			defer func() { <-semaphore }()
+
+			callCtx := ctx
+			timeout := c.ToolExecution.timeout(e.Call.FunctionCall.Name)
+
+			if timeout > 0 {
+				var cancel context.CancelFunc
+
+				callCtx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			e.call(callCtx)
+			e.Done = true
+
+			if e.Err != nil && ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+				e.Err = fmt.Errorf("tool call timed out after %s", timeout)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// prepareToolCall validates the tool call and checks whether it is allowed.
+// An error is returned when the tool call is not allowed in order to re-request the chat completion.
+func prepareToolCall(ctx context.Context, toolCall llms.ToolCall, reqNum int64, fns *tools.CallLoopPreventingProvider, ch chan<- ResponseChunk) (*toolCallExecution, error) {
+	call := toolCall.FunctionCall
+	e := &toolCallExecution{Call: toolCall}
+
+	fn, err := findTool(ctx, call.Name, fns)
+	if err == nil {
+		err = tools.ValidateArguments(fn.Definition(), call.Arguments)
+		if err != nil {
+			var argsErr *tools.ArgumentsError
+			if errors.As(err, &argsErr) {
+				// Let the LLM correct the call without counting it as a call attempt
+				slog.Warn(err.Error())
+
+				e.Result = argsErr.ToolResult()
+				e.Done = true
+
+				return e, nil
+			}
+
+			slog.Warn(fmt.Sprintf("cannot validate tool call arguments: %s", err))
+		}
+	}
+
+	err = fns.CheckToolCall(call)
+	if err != nil {
+		var loopErr *tools.LoopError
+		if errors.As(err, &loopErr) {
+			// Tell the LLM why the call was rejected instead of executing it again
+			slog.Warn(err.Error())
+
+			e.Result = loopErr.ToolResult()
+			e.Done = true
+
+			return e, nil
+		}
+
+		return nil, fmt.Errorf("check tool call: %w", err)
+	}
+
+	if fn == nil {
+		e.Err = fmt.Errorf("tool %q not found", call.Name)
+		e.Done = true
+
+		return e, nil
+	}
+
+	e.Tool = fn
+
+	if call.Name != "answer" {
+		infos := []string{fmt.Sprintf("Let me use my %q tool.", call.Name)}
+		for _, sentence := range infos {
+			ch <- ResponseChunk{
+				Type:       model.MessageTypeChunk,
+				RequestNum: reqNum,
+				Text:       sentence,
+				UserOnly:   true,
+			}
+		}
+	}
+
+	return e, nil
+}
+
+// call runs the tool call, recording its result.
+func (e *toolCallExecution) call(ctx context.Context) {
+	var result tools.Result
+
+	result, e.Err = callTool(ctx, e.Call, e.Tool)
+	e.Result, e.Attachments = result.Text, result.Attachments
+}
+
+// outputSampleRate is the sample rate of the audio output stream that audio clips of tools are played within.
+const outputSampleRate = 16000
+
+// handleToolAttachments plays the audio clips returned by a tool to the user and returns its images as content parts for the LLM.
+// Audio clips that cannot be played are reported within the tool result.
+func handleToolAttachments(e *toolCallExecution, reqNum int64, ch chan<- ResponseChunk) []llms.ContentPart {
+	images := make([]llms.ContentPart, 0, len(e.Attachments)+1)
+
+	for _, a := range e.Attachments {
+		switch {
+		case strings.HasPrefix(a.MIMEType, "image/"):
+			if len(images) == 0 {
+				images = append(images, llms.TextPart(fmt.Sprintf("The %s tool returned the following images:", e.Call.FunctionCall.Name)))
+			}
+
+			images = append(images, llms.BinaryPart(a.MIMEType, a.Data))
+		case strings.HasPrefix(a.MIMEType, "audio/"):
+			err := checkPlayableAudio(a)
+			if err != nil {
+				slog.Warn(fmt.Sprintf("not playing audio returned by the %s tool: %s", e.Call.FunctionCall.Name, err))
+				e.Result += fmt.Sprintf("\n[The %s audio clip was not played to the user: %s]", a.MIMEType, err)
+
+				continue
+			}
+
+			ch <- ResponseChunk{
+				Type:       model.MessageTypeChunk,
+				RequestNum: reqNum,
+				UserOnly:   true,
+				Audio:      a.Data,
+			}
+		default:
+			slog.Warn(fmt.Sprintf("ignoring %s content returned by the %s tool", a.MIMEType, e.Call.FunctionCall.Name))
+		}
+	}
+
+	return images
+}
+
+// checkPlayableAudio returns an error unless the given audio clip matches the format of the audio output stream.
+func checkPlayableAudio(a tools.Attachment) error {
+	if a.MIMEType != "audio/wav" && a.MIMEType != "audio/x-wav" && a.MIMEType != "audio/wave" {
+		return errors.New("only WAV audio is supported")
+	}
+
+	decoder := wav.NewDecoder(bytes.NewReader(a.Data))
+
+	decoder.ReadInfo()
+	if err := decoder.Err(); err != nil {
+		return fmt.Errorf("invalid WAV data: %w", err)
+	}
+
+	if decoder.SampleRate != outputSampleRate || decoder.BitDepth != 16 || decoder.NumChans != 1 {
+		return fmt.Errorf("unsupported WAV format %d Hz, %d bit, %d channels, expected %d Hz, 16 bit mono",
+			decoder.SampleRate, decoder.BitDepth, decoder.NumChans, outputSampleRate)
+	}
+
+	return nil
+}
+
+func callTool(ctx context.Context, call llms.ToolCall, fn tools.Tool) (tools.Result, error) {
+	slog.Debug(fmt.Sprintf("%s tool call %s with args %#v", call.FunctionCall.Name, call.ID, call.FunctionCall.Arguments))
+
+	r, err := tools.Call(ctx, fn, call.FunctionCall.Arguments)
+	if err != nil {
+		return r, err
+	}
+
+	functionCallResult := strings.TrimSpace(r.Text)
+
+	if functionCallResult == "" && len(r.Attachments) == 0 {
+		return r, errors.New("tool call returned empty result")
+	}
+
+	result := ""
+	if len(functionCallResult) > 0 {
+		result = strings.ReplaceAll("\n"+functionCallResult, "\n", "\n\t")
+	}
+
+	slog.Debug(fmt.Sprintf("%s tool result: %s", call.FunctionCall.Name, result))
+
+	return tools.Result{Text: result, Attachments: r.Attachments}, nil
+}
@@ -0,0 +1,136 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/tools"
+	"github.com/orcaman/writerseeker"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+type fakeTool struct {
+	Name     string
+	Duration time.Duration
+}
+
+func (t *fakeTool) Definition() llms.FunctionDefinition {
+	return llms.FunctionDefinition{Name: t.Name}
+}
+
+func (t *fakeTool) Call(ctx context.Context, _ string) (string, error) {
+	select {
+	case <-time.After(t.Duration):
+		return fmt.Sprintf("%s result", t.Name), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func TestHandleToolCalls(t *testing.T) {
+	testee := &LLM{
+		ToolExecution: ToolExecutionPolicy{
+			MaxConcurrency: 3,
+			Timeouts:       map[string]time.Duration{"slow": 50 * time.Millisecond},
+		},
+	}
+	fns := tools.NewCallLoopPreventingProvider([]tools.Tool{
+		&fakeTool{Name: "weather", Duration: 300 * time.Millisecond},
+		&fakeTool{Name: "news", Duration: 100 * time.Millisecond},
+		&fakeTool{Name: "slow", Duration: time.Second},
+	}, tools.LoopPolicy{})
+	conv := model.NewConversation("fake prompt", 0)
+	reqNum := conv.AddUserRequest(llms.TextPart("What's the weather and the news?"))
+	ch := make(chan ResponseChunk, 10)
+	calls := make([]llms.ToolCall, 0, 3)
+
+	for _, name := range []string{"weather", "news", "slow"} {
+		calls = append(calls, llms.ToolCall{
+			ID:           name + "-call",
+			Type:         "function",
+			FunctionCall: &llms.FunctionCall{Name: name, Arguments: "{}"},
+		})
+	}
+
+	start := time.Now()
+	err := testee.handleToolCalls(context.Background(), calls, reqNum, fns, conv, ch)
+	duration := time.Since(start)
+
+	require.Error(t, err, "should request reconciliation")
+	require.Less(t, duration, 400*time.Millisecond, "should call tools concurrently")
+
+	results := make([]string, 0, 3)
+	for _, msg := range conv.Messages() {
+		for _, part := range msg.Parts {
+			if r, ok := part.(llms.ToolCallResponse); ok {
+				results = append(results, fmt.Sprintf("%s: %s", r.Name, r.Content))
+			}
+		}
+	}
+
+	require.Equal(t, []string{
+		"weather: \n\tweather result",
+		"news: \n\tnews result",
+		`slow: ERROR: failed to call tool "slow": tool call timed out after 50ms`,
+	}, results, "tool call results in call order")
+}
+
+func TestHandleToolAttachments(t *testing.T) {
+	playable := fakeWav(t, 16000)
+	e := &toolCallExecution{
+		Call: llms.ToolCall{
+			ID:           "music-call",
+			Type:         "function",
+			FunctionCall: &llms.FunctionCall{Name: "music", Arguments: "{}"},
+		},
+		Result: "[image/png image attached][audio/wav audio clip attached][audio/wav audio clip attached][audio/mpeg audio clip attached]",
+		Attachments: []tools.Attachment{
+			{MIMEType: "image/png", Data: []byte("png")},
+			{MIMEType: "audio/wav", Data: playable},
+			{MIMEType: "audio/wav", Data: fakeWav(t, 44100)},
+			{MIMEType: "audio/mpeg", Data: []byte("mp3")},
+		},
+	}
+	ch := make(chan ResponseChunk, 10)
+
+	images := handleToolAttachments(e, 1, ch)
+	close(ch)
+
+	require.Equal(t, []llms.ContentPart{
+		llms.TextPart("The music tool returned the following images:"),
+		llms.BinaryPart("image/png", []byte("png")),
+	}, images, "images")
+
+	played := make([][]byte, 0, 1)
+	for chunk := range ch {
+		played = append(played, chunk.Audio)
+	}
+
+	require.Equal(t, [][]byte{playable}, played, "played audio")
+	require.Equal(t, "[image/png image attached][audio/wav audio clip attached][audio/wav audio clip attached][audio/mpeg audio clip attached]"+
+		"\n[The audio/wav audio clip was not played to the user: unsupported WAV format 44100 Hz, 16 bit, 1 channels, expected 16000 Hz, 16 bit mono]"+
+		"\n[The audio/mpeg audio clip was not played to the user: only WAV audio is supported]", e.Result, "result")
+}
+
+func fakeWav(t *testing.T, sampleRate int) []byte {
+	out := &writerseeker.WriterSeeker{}
+	encoder := wav.NewEncoder(out, sampleRate, 16, 1, 1)
+
+	err := encoder.Write(&audio.IntBuffer{Format: &audio.Format{SampleRate: sampleRate, NumChannels: 1}, Data: make([]int, 100), SourceBitDepth: 16})
+	require.NoError(t, err)
+
+	err = encoder.Close()
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(out.Reader())
+	require.NoError(t, err)
+
+	return b
+}
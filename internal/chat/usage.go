@@ -0,0 +1,63 @@
+package chat
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+	"github.com/tmc/langchaingo/llms"
+)
+
+const (
+	mainAgentName       = "main"
+	summarizerAgentName = "summarizer"
+)
+
+// UsageRecorder records the amount of tokens used by chat completions.
+type UsageRecorder interface {
+	AddTokenUsage(requestNum int64, agent string, usage model.TokenUsage)
+}
+
+func (c *LLM) agentName() string {
+	if c.Agent == "" {
+		return mainAgentName
+	}
+
+	return c.Agent
+}
+
+// recordUsage attributes the token usage of a chat completion to the LLM's agent and the given request.
+func (c *LLM) recordUsage(reqNum int64, agent string, backend *Backend, usage model.TokenUsage) {
+	if usage == (model.TokenUsage{}) {
+		return
+	}
+
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+
+	slog.Debug(fmt.Sprintf("%s used %d prompt and %d completion tokens of backend %s", agent, usage.PromptTokens, usage.CompletionTokens, backend.Name))
+
+	if c.Usage != nil {
+		c.Usage.AddTokenUsage(reqNum, agent, usage)
+	}
+}
+
+// usageFromResponse returns the token usage reported within a langchaingo response.
+func usageFromResponse(resp *llms.ContentResponse) model.TokenUsage {
+	if resp == nil || len(resp.Choices) == 0 {
+		return model.TokenUsage{}
+	}
+
+	info := resp.Choices[0].GenerationInfo
+	tokens := func(key string) int {
+		n, _ := info[key].(int)
+		return n
+	}
+
+	return model.TokenUsage{
+		PromptTokens:     tokens("PromptTokens"),
+		CompletionTokens: tokens("CompletionTokens"),
+		TotalTokens:      tokens("TotalTokens"),
+	}
+}
@@ -2,10 +2,14 @@ package model
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/tmc/langchaingo/llms"
 )
@@ -13,15 +17,94 @@ import (
 type Conversation struct {
 	requestCounter int64
 	cancelFuncs    []context.CancelFunc
-	messages       []conversationMessage
+	messages       []ConversationMessage
+	historyPolicy  HistoryPolicy
+	summary        string
+	reasoning      []Reasoning
+	usage          UsageStats
+	evicted        []ConversationMessage
+	summarizeMutex sync.Mutex
+	store          ConversationStore
+	id             string
+	persistTimer   *time.Timer
+	saveMutex      sync.Mutex
 	mutex          sync.Mutex
 }
 
-type conversationMessage struct {
-	RequestNum int64
+// persistDelay is the time changes are collected before the conversation is saved.
+// This is to avoid rewriting the persisted conversation for every spoken sentence.
+const persistDelay = time.Second
+
+type ConversationMessage struct {
+	RequestNum int64     `json:"requestNum"`
+	Time       time.Time `json:"time"`
+	// Transcription is the transcription of a voice request that is passed to the model as audio.
+	Transcription string `json:"transcription,omitempty"`
 	llms.MessageContent
 }
 
+// MarshalJSON overrides the MarshalJSON method promoted from the embedded llms.MessageContent.
+func (m ConversationMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		RequestNum    int64               `json:"requestNum"`
+		Time          time.Time           `json:"time"`
+		Transcription string              `json:"transcription,omitempty"`
+		Message       llms.MessageContent `json:"message"`
+	}{
+		RequestNum:    m.RequestNum,
+		Time:          m.Time,
+		Transcription: m.Transcription,
+		Message:       m.MessageContent,
+	})
+}
+
+// UnmarshalJSON overrides the UnmarshalJSON method promoted from the embedded llms.MessageContent.
+func (m *ConversationMessage) UnmarshalJSON(data []byte) error {
+	var msg struct {
+		RequestNum    int64               `json:"requestNum"`
+		Time          time.Time           `json:"time"`
+		Transcription string              `json:"transcription"`
+		Message       llms.MessageContent `json:"message"`
+	}
+
+	err := json.Unmarshal(data, &msg)
+	if err != nil {
+		return err
+	}
+
+	m.RequestNum = msg.RequestNum
+	m.Time = msg.Time
+	m.Transcription = msg.Transcription
+	m.MessageContent = msg.Message
+
+	return nil
+}
+
+// Reasoning is what a reasoning model thought before responding to a request.
+// It is kept separately since it must neither be spoken nor sent back to the model.
+type Reasoning struct {
+	RequestNum int64     `json:"requestNum"`
+	Time       time.Time `json:"time"`
+	Text       string    `json:"text"`
+}
+
+// ConversationState is the persistable state of a conversation.
+type ConversationState struct {
+	RequestCounter int64                 `json:"requestCounter"`
+	Messages       []ConversationMessage `json:"messages"`
+	Summary        string                `json:"summary,omitempty"`
+	Evicted        []ConversationMessage `json:"evicted,omitempty"`
+	Reasoning      []Reasoning           `json:"reasoning,omitempty"`
+	Usage          UsageStats            `json:"usage"`
+}
+
+// ConversationStore persists the state of conversations by ID.
+type ConversationStore interface {
+	// Load returns the state of the conversation with the given ID and false if it does not exist.
+	Load(id string) (ConversationState, bool, error)
+	Save(id string, state ConversationState) error
+}
+
 func FormatMessage(m llms.MessageContent) string {
 	return fmt.Sprintf("%s: %s", m.Role, formatMessageParts(m.Parts))
 }
@@ -34,11 +117,11 @@ func formatMessageParts(parts []llms.ContentPart) string {
 		case llms.TextContent:
 			strs[i] = part.Text
 		case llms.ToolCall:
-			strs[i] = fmt.Sprintf("{%s %s(%s)}", part.ID[:5], part.FunctionCall.Name, part.FunctionCall.Arguments)
+			strs[i] = fmt.Sprintf("{%s %s(%s)}", shortID(part.ID), part.FunctionCall.Name, part.FunctionCall.Arguments)
 		case llms.ToolCallResponse:
-			strs[i] = fmt.Sprintf("&%s %q", part.ToolCallID[:5], part.Content)
+			strs[i] = fmt.Sprintf("&%s %q", shortID(part.ToolCallID), part.Content)
 		case llms.BinaryContent:
-			strs[i] = "[binary]"
+			strs[i] = fmt.Sprintf("[%s]", part.MIMEType)
 		default:
 			strs[i] = fmt.Sprintf("%T%v", p, p)
 		}
@@ -47,10 +130,21 @@ func formatMessageParts(parts []llms.ContentPart) string {
 	return strings.Join(strs, "")
 }
 
+// shortID returns the beginning of the given tool call ID.
+// Providers may return IDs of any length.
+func shortID(id string) string {
+	if len(id) > 5 {
+		return id[:5]
+	}
+
+	return id
+}
+
 func NewConversation(systemPrompt string, reqNum int64) *Conversation {
-	messages := make([]conversationMessage, 1, 100)
-	messages[0] = conversationMessage{
+	messages := make([]ConversationMessage, 1, 100)
+	messages[0] = ConversationMessage{
 		RequestNum:     reqNum,
+		Time:           time.Now(),
 		MessageContent: llms.TextParts(llms.ChatMessageTypeSystem, systemPrompt),
 	}
 
@@ -60,6 +154,36 @@ func NewConversation(systemPrompt string, reqNum int64) *Conversation {
 	}
 }
 
+// RestoreConversation creates a conversation from a previously persisted state.
+func RestoreConversation(state ConversationState) (*Conversation, error) {
+	if len(state.Messages) == 0 || state.Messages[0].Role != llms.ChatMessageTypeSystem {
+		return nil, errors.New("restore conversation: persisted state does not start with a system prompt")
+	}
+
+	messages := make([]ConversationMessage, len(state.Messages), max(len(state.Messages), 100))
+	copy(messages, state.Messages)
+
+	return &Conversation{
+		messages:       messages,
+		requestCounter: state.RequestCounter,
+		summary:        state.Summary,
+		evicted:        slices.Clone(state.Evicted),
+		reasoning:      slices.Clone(state.Reasoning),
+		usage:          state.Usage.clone(),
+	}, nil
+}
+
+// PersistTo saves the conversation's state to the given store and makes it save changes after the persistDelay.
+func (c *Conversation) PersistTo(id string, store ConversationStore) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.id = id
+	c.store = store
+
+	return c.store.Save(c.id, c.persistedState())
+}
+
 func (c *Conversation) AddCancelFunc(fn context.CancelFunc) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
@@ -79,13 +203,23 @@ func (c *Conversation) SetSystemPrompt(prompt string) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	c.messages[0] = conversationMessage{
+	c.messages[0] = ConversationMessage{
 		RequestNum:     c.requestCounter,
+		Time:           time.Now(),
 		MessageContent: llms.TextParts(llms.ChatMessageTypeSystem, prompt),
 	}
+
+	c.persist()
+}
+
+// AddUserRequest adds a new user request consisting of the given parts, e.g. text and images.
+func (c *Conversation) AddUserRequest(parts ...llms.ContentPart) int64 {
+	return c.AddVoiceRequest("", parts...)
 }
 
-func (c *Conversation) AddUserRequest(msgContent llms.ContentPart) int64 {
+// AddVoiceRequest adds a new user request that contains the recorded audio along with its transcription.
+// The transcription is not passed to the model but shown within the transcript and persisted instead of the audio.
+func (c *Conversation) AddVoiceRequest(transcription string, parts ...llms.ContentPart) int64 {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
@@ -97,19 +231,25 @@ func (c *Conversation) AddUserRequest(msgContent llms.ContentPart) int64 {
 
 	c.cancelFuncs = nil
 
-	cmsg := conversationMessage{
-		RequestNum: c.requestCounter,
+	cmsg := ConversationMessage{
+		RequestNum:    c.requestCounter,
+		Time:          time.Now(),
+		Transcription: transcription,
 		MessageContent: llms.MessageContent{
 			Role:  llms.ChatMessageTypeHuman,
-			Parts: []llms.ContentPart{msgContent},
+			Parts: parts,
 		},
 	}
 	msgStr := formatMessageParts(cmsg.MessageContent.Parts)
+	if transcription != "" {
+		msgStr = fmt.Sprintf("%s (%s)", msgStr, transcription)
+	}
 
 	slog.Info(fmt.Sprintf("user request: %s", strings.TrimSpace(msgStr)))
 
-	c.dropPreviousMessages()
+	c.applyHistoryPolicy()
 	c.addMessage(cmsg)
+	c.persist()
 
 	return c.requestCounter
 }
@@ -122,10 +262,12 @@ func (c *Conversation) AddAIResponse(requestNum int64, msg string) bool {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if c.addMessage(conversationMessage{
+	if c.addMessage(ConversationMessage{
 		RequestNum:     requestNum,
+		Time:           time.Now(),
 		MessageContent: llms.TextParts(llms.ChatMessageTypeAI, msg),
 	}) {
+		c.persist()
 		slog.Info(fmt.Sprintf("assistant: %s", strings.TrimSpace(msg)))
 		return true
 	}
@@ -142,16 +284,20 @@ func (c *Conversation) AddToolCallResponse(requestNum int64, call llms.ToolCall,
 		return
 	}
 
+	now := time.Now()
+
 	c.messages = append(c.messages,
-		conversationMessage{
+		ConversationMessage{
 			RequestNum: requestNum,
+			Time:       now,
 			MessageContent: llms.MessageContent{
 				Role:  llms.ChatMessageTypeAI,
 				Parts: []llms.ContentPart{call},
 			},
 		},
-		conversationMessage{
+		ConversationMessage{
 			RequestNum: requestNum,
+			Time:       now,
 			MessageContent: llms.MessageContent{
 				Role: llms.ChatMessageTypeTool,
 				Parts: []llms.ContentPart{llms.ToolCallResponse{
@@ -161,9 +307,48 @@ func (c *Conversation) AddToolCallResponse(requestNum int64, call llms.ToolCall,
 				}},
 			},
 		})
+
+	c.persist()
 }
 
-func (c *Conversation) addMessage(msg conversationMessage) bool {
+// AddToolContent adds content returned by tools that cannot be passed as tool result, e.g. images.
+// Since tool messages can contain text only, the content is added as user message to the given request.
+func (c *Conversation) AddToolContent(requestNum int64, parts ...llms.ContentPart) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.addMessage(ConversationMessage{
+		RequestNum: requestNum,
+		Time:       time.Now(),
+		MessageContent: llms.MessageContent{
+			Role:  llms.ChatMessageTypeHuman,
+			Parts: parts,
+		},
+	}) {
+		c.persist()
+	}
+}
+
+// AddReasoning stores the reasoning of the model that preceded its response to the given request.
+func (c *Conversation) AddReasoning(requestNum int64, reasoning string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.requestCounter > requestNum || reasoning == "" {
+		// ignore reasoning of an outdated request
+		return
+	}
+
+	c.reasoning = append(c.reasoning, Reasoning{
+		RequestNum: requestNum,
+		Time:       time.Now(),
+		Text:       reasoning,
+	})
+
+	c.persist()
+}
+
+func (c *Conversation) addMessage(msg ConversationMessage) bool {
 	if c.requestCounter > msg.RequestNum {
 		// ignore response from an outdated request
 		return false
@@ -175,6 +360,7 @@ func (c *Conversation) addMessage(msg conversationMessage) bool {
 		// TODO: add whitespace
 		messages[len(messages)-1].Parts = append(messages[len(messages)-1].Parts, msg.Parts...)
 		messages[len(messages)-1].RequestNum = msg.RequestNum
+		messages[len(messages)-1].Transcription = strings.TrimSpace(messages[len(messages)-1].Transcription + " " + msg.Transcription)
 	} else {
 		messages = append(messages, msg)
 	}
@@ -184,24 +370,157 @@ func (c *Conversation) addMessage(msg conversationMessage) bool {
 	return true
 }
 
-func (c *Conversation) dropPreviousMessages() {
-	filtered := make([]conversationMessage, 0, len(c.messages)+1)
+// State returns a copy of the conversation's state.
+func (c *Conversation) State() ConversationState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	state := c.state()
+	state.Messages = slices.Clone(state.Messages)
+	state.Evicted = slices.Clone(state.Evicted)
+	state.Reasoning = slices.Clone(state.Reasoning)
+	state.Usage = state.Usage.clone()
+
+	return state
+}
+
+// Reset drops all messages except the system prompt and cancels the current request.
+// The token usage is kept since the daily token budget must not be bypassed by resetting the conversation.
+func (c *Conversation) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Incrementing the counter marks pending responses as outdated.
+	c.requestCounter++
+
+	for _, cancel := range c.cancelFuncs {
+		cancel()
+	}
+
+	c.cancelFuncs = nil
+	c.messages = c.messages[:1]
+	c.messages[0].RequestNum = c.requestCounter
+	c.summary = ""
+	c.reasoning = nil
+	c.evicted = nil
+
+	slog.Info("conversation reset")
+
+	c.persist()
+}
+
+func (c *Conversation) state() ConversationState {
+	return ConversationState{
+		RequestCounter: c.requestCounter,
+		Messages:       c.messages,
+		Summary:        c.summary,
+		Evicted:        c.evicted,
+		Reasoning:      c.reasoning,
+		Usage:          c.usage,
+	}
+}
+
+// persistedState returns the state to persist.
+// Binary content such as images and audio is replaced with a placeholder or the transcription of the audio
+// since the state is rewritten whenever the conversation changes.
+func (c *Conversation) persistedState() ConversationState {
+	state := c.state()
+	state.Messages = withoutBinaryContent(state.Messages)
+	state.Evicted = withoutBinaryContent(state.Evicted)
+	state.Reasoning = slices.Clone(state.Reasoning)
+	state.Usage = state.Usage.clone()
+
+	return state
+}
+
+func withoutBinaryContent(messages []ConversationMessage) []ConversationMessage {
+	result := slices.Clone(messages)
+
+	for i, msg := range result {
+		if !slices.ContainsFunc(msg.Parts, isBinaryContent) {
+			continue
+		}
 
-	for i, msg := range c.messages {
-		if i == 0 || msg.RequestNum == c.requestCounter {
-			filtered = append(filtered, msg)
+		parts := make([]llms.ContentPart, 0, len(msg.Parts))
+		transcription := msg.Transcription
+
+		for _, p := range msg.Parts {
+			if b, ok := p.(llms.BinaryContent); ok {
+				switch {
+				case !strings.HasPrefix(b.MIMEType, "audio/") || msg.Transcription == "":
+					p = llms.TextPart(fmt.Sprintf("[%s omitted]", b.MIMEType))
+				case transcription != "":
+					// The transcription covers all audio clips of the message.
+					p = llms.TextPart(transcription)
+					transcription = ""
+				default:
+					continue
+				}
+			}
+
+			parts = append(parts, p)
 		}
+
+		result[i].Parts = parts
+	}
+
+	return result
+}
+
+func isBinaryContent(p llms.ContentPart) bool {
+	_, ok := p.(llms.BinaryContent)
+	return ok
+}
+
+// persist schedules saving the conversation unless it is scheduled already.
+func (c *Conversation) persist() {
+	if c.store == nil || c.persistTimer != nil {
+		return
 	}
 
-	c.messages = filtered
+	c.persistTimer = time.AfterFunc(persistDelay, c.Flush)
+}
+
+// Flush saves pending changes of the conversation immediately.
+func (c *Conversation) Flush() {
+	// Serialize saves to prevent an older state from overwriting a newer one.
+	c.saveMutex.Lock()
+	defer c.saveMutex.Unlock()
+
+	c.mutex.Lock()
+
+	if c.persistTimer == nil {
+		c.mutex.Unlock()
+		return
+	}
+
+	c.persistTimer.Stop()
+	c.persistTimer = nil
+	id, store, state := c.id, c.store, c.persistedState()
+
+	c.mutex.Unlock()
+
+	err := store.Save(id, state)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to persist conversation %s: %s", id, err))
+	}
 }
 
 func (c *Conversation) Messages() []llms.MessageContent {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	msgs := c.messages
 	msgContents := make([]llms.MessageContent, len(msgs))
 
 	for i, msg := range msgs {
-		msgContents[i] = msg.MessageContent
+		msgContents[i] = llms.MessageContent{Role: msg.Role, Parts: slices.Clone(msg.Parts)}
+	}
+
+	if c.summary != "" {
+		// Provide the summary of evicted requests along with the system prompt
+		msgContents[0] = llms.TextParts(llms.ChatMessageTypeSystem,
+			fmt.Sprintf("%s\n\nSummary of the earlier conversation:\n%s", formatMessageParts(msgs[0].Parts), c.summary))
 	}
 
 	return msgContents
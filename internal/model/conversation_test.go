@@ -0,0 +1,85 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+type fakeConversationStore map[string]ConversationState
+
+func (s fakeConversationStore) Load(id string) (ConversationState, bool, error) {
+	state, ok := s[id]
+	return state, ok, nil
+}
+
+func (s fakeConversationStore) Save(id string, state ConversationState) error {
+	s[id] = state
+	return nil
+}
+
+func TestConversationPersistsWithoutBinaryContent(t *testing.T) {
+	store := fakeConversationStore{}
+	testee := NewConversation("fake prompt", 0)
+
+	err := testee.PersistTo("kitchen", store)
+	require.NoError(t, err)
+
+	testee.AddUserRequest(llms.TextPart("What's this plant?"), llms.BinaryPart("image/png", []byte("plant")))
+	testee.Flush()
+
+	persisted := store["kitchen"].Messages
+	require.Len(t, persisted, 2, "persisted messages")
+	require.Equal(t, []llms.ContentPart{llms.TextPart("What's this plant?"), llms.TextPart("[image/png omitted]")}, persisted[1].Parts, "persisted parts")
+
+	messages := testee.Messages()
+	require.Equal(t, llms.BinaryPart("image/png", []byte("plant")), messages[1].Parts[1], "image within the running conversation")
+}
+
+func TestConversationPersistsTranscriptionInsteadOfAudio(t *testing.T) {
+	store := fakeConversationStore{}
+	testee := NewConversation("fake prompt", 0)
+
+	err := testee.PersistTo("kitchen", store)
+	require.NoError(t, err)
+
+	testee.AddVoiceRequest("Computer, how are you?", llms.BinaryPart("audio/wav", []byte("wav")), llms.BinaryPart("image/png", []byte("png")))
+	testee.Flush()
+
+	persisted := store["kitchen"].Messages
+	require.Len(t, persisted, 2, "persisted messages")
+	require.Equal(t, []llms.ContentPart{llms.TextPart("Computer, how are you?"), llms.TextPart("[image/png omitted]")}, persisted[1].Parts, "persisted parts")
+	require.Equal(t, "Computer, how are you?", persisted[1].Transcription, "persisted transcription")
+
+	messages := testee.Messages()
+	require.Equal(t, []llms.ContentPart{llms.BinaryPart("audio/wav", []byte("wav")), llms.BinaryPart("image/png", []byte("png"))}, messages[1].Parts, "parts passed to the model")
+}
+
+func TestConversationPersistsChangesDelayed(t *testing.T) {
+	store := fakeConversationStore{}
+	testee := NewConversation("fake prompt", 0)
+
+	err := testee.PersistTo("kitchen", store)
+	require.NoError(t, err)
+
+	reqNum := testee.AddUserRequest(llms.TextPart("Tell me a story."))
+	testee.AddAIResponse(reqNum, "Once upon a time")
+	testee.AddAIResponse(reqNum, " there was a cat.")
+
+	require.Len(t, store["kitchen"].Messages, 1, "persisted messages before the persist delay")
+
+	testee.Flush()
+
+	require.Len(t, store["kitchen"].Messages, 3, "persisted messages after flush")
+}
+
+func TestFormatMessageShortToolCallID(t *testing.T) {
+	call := llms.ToolCall{ID: "c1", Type: "function", FunctionCall: &llms.FunctionCall{Name: "lookup", Arguments: "{}"}}
+
+	require.Equal(t, "ai: {c1 lookup({})}", FormatMessage(llms.MessageContent{Role: llms.ChatMessageTypeAI, Parts: []llms.ContentPart{call}}))
+	require.Equal(t, `tool: &c1 "result"`, FormatMessage(llms.MessageContent{
+		Role:  llms.ChatMessageTypeTool,
+		Parts: []llms.ContentPart{llms.ToolCallResponse{ToolCallID: "c1", Name: "lookup", Content: "result"}},
+	}))
+}
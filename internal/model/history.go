@@ -0,0 +1,205 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// HistoryPolicy specifies which messages of previous requests are kept within a conversation.
+// The zero value keeps the messages of the current request only.
+type HistoryPolicy struct {
+	// MaxRequests is the number of previous requests to keep.
+	MaxRequests int
+	// MaxTokens is the (estimated) maximum amount of tokens the message history may contain.
+	// When exceeded, the oldest requests are evicted, even if MaxRequests is not reached yet.
+	MaxTokens int
+	// KeepToolCalls specifies whether to keep the tool calls and responses of previous requests.
+	KeepToolCalls bool
+	// Summarize specifies whether requests that are evicted due to the token budget should be summarized instead of dropping them.
+	// Requests that exceed MaxRequests are dropped without summary.
+	Summarize bool
+}
+
+// SummarizeFunc summarizes the given messages, extending the previous summary.
+type SummarizeFunc func(ctx context.Context, summary string, messages []llms.MessageContent) (string, error)
+
+func (c *Conversation) SetHistoryPolicy(policy HistoryPolicy) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.historyPolicy = policy
+}
+
+// Summary returns the summary of the requests that were evicted from the message history.
+func (c *Conversation) Summary() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.summary
+}
+
+// SummarizeEvicted summarizes the messages that were evicted from the history since the last call.
+// Messages that could not be summarized are kept for the next call.
+func (c *Conversation) SummarizeEvicted(ctx context.Context, summarize SummarizeFunc) error {
+	c.summarizeMutex.Lock()
+	defer c.summarizeMutex.Unlock()
+
+	c.mutex.Lock()
+	evicted := slices.Clone(c.evicted)
+	summary := c.summary
+	c.mutex.Unlock()
+
+	if len(evicted) == 0 {
+		return nil
+	}
+
+	messages := make([]llms.MessageContent, len(evicted))
+	for i, msg := range evicted {
+		messages[i] = msg.MessageContent
+	}
+
+	slog.Debug(fmt.Sprintf("summarizing %d evicted messages", len(messages)))
+
+	summary, err := summarize(ctx, summary, messages)
+	if err != nil {
+		return fmt.Errorf("summarize evicted messages: %w", err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.evicted) < len(evicted) || c.evicted[0].RequestNum != evicted[0].RequestNum {
+		return nil // the conversation was reset meanwhile
+	}
+
+	c.evicted = c.evicted[len(evicted):]
+	c.summary = summary
+	c.persist()
+
+	return nil
+}
+
+// applyHistoryPolicy evicts the messages of previous requests according to the history policy.
+func (c *Conversation) applyHistoryPolicy() {
+	policy := c.historyPolicy
+	minRequestNum := c.requestCounter - int64(policy.MaxRequests)
+	kept := make([]ConversationMessage, 0, len(c.messages)+1)
+	evicted := make([]ConversationMessage, 0, len(c.messages))
+
+	for i, msg := range c.messages {
+		switch {
+		case i == 0 || msg.RequestNum == c.requestCounter:
+			kept = append(kept, msg)
+		case msg.RequestNum < minRequestNum:
+			// Drop the request without summary.
+		case !policy.KeepToolCalls:
+			if msg = withoutToolCalls(msg); len(msg.Parts) > 0 {
+				kept = append(kept, msg)
+			}
+		default:
+			kept = append(kept, msg)
+		}
+	}
+
+	if policy.MaxTokens > 0 {
+		// Evict the oldest requests until the history fits into the token budget.
+		tokens := estimateTokens(kept)
+
+		for tokens > policy.MaxTokens && len(kept) > 1 && kept[1].RequestNum < c.requestCounter {
+			reqNum := kept[1].RequestNum
+
+			for len(kept) > 1 && kept[1].RequestNum == reqNum {
+				tokens -= estimateMessageTokens(kept[1])
+				evicted = append(evicted, kept[1])
+				kept = append(kept[:1], kept[2:]...)
+			}
+		}
+	}
+
+	c.messages = kept
+
+	// Drop the reasoning of evicted requests
+	minRequestNum = c.requestCounter
+	if len(kept) > 1 {
+		minRequestNum = kept[1].RequestNum
+	}
+
+	c.reasoning = slices.DeleteFunc(c.reasoning, func(r Reasoning) bool {
+		return r.RequestNum < minRequestNum
+	})
+
+	if policy.Summarize {
+		for _, msg := range evicted {
+			if msg = withoutToolCalls(msg); len(msg.Parts) > 0 {
+				c.evicted = append(c.evicted, msg)
+			}
+		}
+	}
+}
+
+func withoutToolCalls(msg ConversationMessage) ConversationMessage {
+	parts := make([]llms.ContentPart, 0, len(msg.Parts))
+
+	for _, p := range msg.Parts {
+		switch p.(type) {
+		case llms.ToolCall, llms.ToolCallResponse:
+		default:
+			parts = append(parts, p)
+		}
+	}
+
+	msg.Parts = parts
+
+	return msg
+}
+
+func estimateTokens(messages []ConversationMessage) int {
+	tokens := 0
+
+	for _, msg := range messages {
+		tokens += estimateMessageTokens(msg)
+	}
+
+	return tokens
+}
+
+const (
+	// imageTokens is roughly the amount of tokens vision models use to encode an image.
+	imageTokens = 768
+	// audioTokensPerSecond is roughly the amount of tokens audio models use to encode a second of audio.
+	audioTokensPerSecond = 25
+	// audioBytesPerSecond is the data rate of 16kHz 16bit mono audio.
+	audioBytesPerSecond = 2 * 16000
+)
+
+// estimateMessageTokens roughly estimates the amount of tokens of a message, assuming 4 characters per token.
+// This is to avoid depending on the tokenizer of a particular model.
+func estimateMessageTokens(msg ConversationMessage) int {
+	chars := len(msg.Role) + 2
+	tokens := 0
+
+	for _, p := range msg.Parts {
+		if b, ok := p.(llms.BinaryContent); ok {
+			tokens += estimateBinaryContentTokens(b)
+			continue
+		}
+
+		chars += len(formatMessageParts([]llms.ContentPart{p}))
+	}
+
+	return tokens + (chars+3)/4
+}
+
+// estimateBinaryContentTokens roughly estimates the amount of tokens of an image or audio clip.
+func estimateBinaryContentTokens(b llms.BinaryContent) int {
+	if strings.HasPrefix(b.MIMEType, "audio/") {
+		return max(len(b.Data)*audioTokensPerSecond/audioBytesPerSecond, 1)
+	}
+
+	return imageTokens
+}
@@ -0,0 +1,200 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestConversationHistoryPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		policy   HistoryPolicy
+		expected []string
+	}{
+		{
+			name:   "keeps current request only by default",
+			policy: HistoryPolicy{},
+			expected: []string{
+				"system: fake prompt",
+				"human: request 3",
+			},
+		},
+		{
+			name:   "keeps previous requests without tool calls",
+			policy: HistoryPolicy{MaxRequests: 1},
+			expected: []string{
+				"system: fake prompt",
+				"human: request 2",
+				"ai: response 2",
+				"human: request 3",
+			},
+		},
+		{
+			name:   "keeps tool calls",
+			policy: HistoryPolicy{MaxRequests: 1, KeepToolCalls: true},
+			expected: []string{
+				"system: fake prompt",
+				"human: request 2",
+				"ai: {call2 lookup({})}",
+				"tool: &call2 \"result 2\"",
+				"ai: response 2",
+				"human: request 3",
+			},
+		},
+		{
+			name:   "evicts oldest requests when token budget is exceeded",
+			policy: HistoryPolicy{MaxRequests: 5, MaxTokens: 15},
+			expected: []string{
+				"system: fake prompt",
+				"human: request 2",
+				"ai: response 2",
+				"human: request 3",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testee := NewConversation("fake prompt", 0)
+			testee.SetHistoryPolicy(tc.policy)
+
+			addFakeRequests(testee, 3)
+
+			actual := make([]string, 0, len(tc.expected))
+			for _, msg := range testee.Messages() {
+				actual = append(actual, FormatMessage(msg))
+			}
+
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestConversationHistoryPolicyEstimatesImageTokens(t *testing.T) {
+	testee := NewConversation("fake prompt", 0)
+	testee.SetHistoryPolicy(HistoryPolicy{MaxRequests: 5, MaxTokens: 500})
+
+	reqNum := testee.AddUserRequest(llms.TextPart("What is this?"), llms.BinaryPart("image/png", []byte("fake image")))
+	testee.AddAIResponse(reqNum, "A cat.")
+	reqNum = testee.AddUserRequest(llms.TextPart("request 2"))
+	testee.AddAIResponse(reqNum, "response 2")
+	testee.AddUserRequest(llms.TextPart("request 3"))
+
+	actual := make([]string, 0, 5)
+	for _, msg := range testee.Messages() {
+		actual = append(actual, FormatMessage(msg))
+	}
+
+	require.Equal(t, []string{
+		"system: fake prompt",
+		"human: request 2",
+		"ai: response 2",
+		"human: request 3",
+	}, actual, "the image request should exceed the token budget")
+}
+
+func TestConversationSummarizeEvicted(t *testing.T) {
+	testee := NewConversation("fake prompt", 0)
+	testee.SetHistoryPolicy(HistoryPolicy{MaxRequests: 5, MaxTokens: 40, KeepToolCalls: true, Summarize: true})
+
+	addFakeRequests(testee, 3)
+
+	// The evicted messages must survive a restart until they are summarized.
+	testee, err := RestoreConversation(testee.State())
+	require.NoError(t, err)
+
+	var summarized []string
+	summarize := func(_ context.Context, summary string, messages []llms.MessageContent) (string, error) {
+		for _, msg := range messages {
+			summarized = append(summarized, FormatMessage(msg))
+		}
+
+		return "fake summary", nil
+	}
+
+	err = testee.SummarizeEvicted(context.Background(), summarize)
+	require.NoError(t, err)
+	require.Equal(t, []string{"human: request 1", "ai: response 1"}, summarized, "summarized messages")
+	require.Equal(t, "fake summary", testee.Summary(), "summary")
+	require.True(t, strings.HasSuffix(FormatMessage(testee.Messages()[0]), "fake summary"), "system prompt should contain summary")
+	require.Empty(t, testee.State().Evicted, "evicted messages after summary")
+
+	summarized = nil
+
+	err = testee.SummarizeEvicted(context.Background(), summarize)
+	require.NoError(t, err)
+	require.Empty(t, summarized, "messages summarized again")
+}
+
+func TestConversationSummarizeEvictedFailure(t *testing.T) {
+	testee := NewConversation("fake prompt", 0)
+	testee.SetHistoryPolicy(HistoryPolicy{MaxRequests: 5, MaxTokens: 40, KeepToolCalls: true, Summarize: true})
+
+	addFakeRequests(testee, 3)
+
+	err := testee.SummarizeEvicted(context.Background(), func(context.Context, string, []llms.MessageContent) (string, error) {
+		return "", errors.New("fake error")
+	})
+	require.Error(t, err)
+	require.Len(t, testee.State().Evicted, 2, "evicted messages kept for the next attempt")
+}
+
+func TestConversationMessagesWhileSummarizing(t *testing.T) {
+	testee := NewConversation("fake prompt", 0)
+	testee.SetHistoryPolicy(HistoryPolicy{MaxRequests: 5, MaxTokens: 40, KeepToolCalls: true, Summarize: true})
+
+	addFakeRequests(testee, 3)
+
+	done := make(chan error)
+
+	go func() {
+		done <- testee.SummarizeEvicted(context.Background(), func(context.Context, string, []llms.MessageContent) (string, error) {
+			return "fake summary", nil
+		})
+	}()
+
+	for summarizing := true; summarizing; {
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+			summarizing = false
+		default:
+			require.NotEmpty(t, testee.Messages())
+		}
+	}
+
+	require.True(t, strings.HasSuffix(FormatMessage(testee.Messages()[0]), "fake summary"), "system prompt should contain summary")
+}
+
+func TestConversationDoesNotSummarizeRequestsExceedingMaxRequests(t *testing.T) {
+	testee := NewConversation("fake prompt", 0)
+	testee.SetHistoryPolicy(HistoryPolicy{MaxRequests: 1, MaxTokens: 4000, Summarize: true})
+
+	addFakeRequests(testee, 3)
+
+	err := testee.SummarizeEvicted(context.Background(), func(context.Context, string, []llms.MessageContent) (string, error) {
+		t.Error("unexpected summary")
+		return "", nil
+	})
+	require.NoError(t, err)
+	require.Empty(t, testee.Summary(), "summary")
+}
+
+func addFakeRequests(c *Conversation, n int) {
+	for i := 1; i <= n; i++ {
+		reqNum := c.AddUserRequest(llms.TextPart(fmt.Sprintf("request %d", i)))
+
+		if i < n {
+			c.AddToolCallResponse(reqNum, llms.ToolCall{
+				ID:           fmt.Sprintf("call%d", i),
+				Type:         "function",
+				FunctionCall: &llms.FunctionCall{Name: "lookup", Arguments: "{}"},
+			}, fmt.Sprintf("result %d", i))
+			c.AddAIResponse(reqNum, fmt.Sprintf("response %d", i))
+		}
+	}
+}
@@ -7,11 +7,38 @@ const (
 	MessageTypeEnd   MessageType = "end"
 )
 
+// Image is an image attached to a user request.
+type Image struct {
+	MIMEType string
+	Data     []byte
+}
+
+// MaxPendingImages is the maximum amount of images that are kept until they can be attached to a user request.
+const MaxPendingImages = 5
+
+// AppendPendingImages appends images to the ones that are waiting for the next user request, keeping the most recent ones only.
+func AppendPendingImages(pending []Image, images ...Image) []Image {
+	pending = append(pending, images...)
+	if len(pending) > MaxPendingImages {
+		pending = pending[len(pending)-MaxPendingImages:]
+	}
+
+	return pending
+}
+
 type Message struct {
 	Type       MessageType
 	RequestNum int64
 	Text       string
 	UserOnly   bool
+	// Voice is the TTS voice the message should be spoken with (default voice when empty).
+	Voice string
+	// Images are attached to a user request.
+	Images []Image
+	// Locale is the user's locale as provided by the client, e.g. en-US.
+	Locale string
+	// Audio is a WAV clip that is played to the user instead of speaking the text, e.g. returned by a tool.
+	Audio []byte
 }
 
 type AudioMessage struct {
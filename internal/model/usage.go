@@ -0,0 +1,133 @@
+package model
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+const (
+	maxRequestUsageEntries = 50
+	maxDailyUsageEntries   = 31
+	usageDateFormat        = time.DateOnly
+)
+
+// TokenUsage is the amount of tokens used by chat completions.
+type TokenUsage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+func (u TokenUsage) Add(o TokenUsage) TokenUsage {
+	return TokenUsage{
+		PromptTokens:     u.PromptTokens + o.PromptTokens,
+		CompletionTokens: u.CompletionTokens + o.CompletionTokens,
+		TotalTokens:      u.TotalTokens + o.TotalTokens,
+	}
+}
+
+// RequestTokenUsage is the amount of tokens used to respond to a request.
+type RequestTokenUsage struct {
+	RequestNum int64 `json:"requestNum"`
+	TokenUsage
+}
+
+// UsageStats aggregates the token usage of a conversation.
+type UsageStats struct {
+	Total TokenUsage `json:"total"`
+	// Daily is the usage by date (YYYY-MM-DD) for the last days.
+	Daily map[string]TokenUsage `json:"daily,omitempty"`
+	// Agents is the usage by agent name.
+	Agents map[string]TokenUsage `json:"agents,omitempty"`
+	// Requests is the usage of the last requests.
+	Requests []RequestTokenUsage `json:"requests,omitempty"`
+}
+
+// DailyUsage returns the amount of tokens used at the day of the given time.
+func (s UsageStats) DailyUsage(t time.Time) TokenUsage {
+	return s.Daily[t.Format(usageDateFormat)]
+}
+
+func (s UsageStats) clone() UsageStats {
+	s.Daily = maps.Clone(s.Daily)
+	s.Agents = maps.Clone(s.Agents)
+	s.Requests = slices.Clone(s.Requests)
+
+	return s
+}
+
+// AddTokenUsage records the tokens an agent used to respond to the given request.
+// Usage that cannot be attributed to a request is recorded with request number 0.
+// In contrast to the messages, the usage is kept when the conversation is reset.
+func (c *Conversation) AddTokenUsage(requestNum int64, agent string, usage TokenUsage) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := &c.usage
+	date := time.Now().Format(usageDateFormat)
+
+	if s.Daily == nil {
+		s.Daily = map[string]TokenUsage{}
+	}
+
+	if s.Agents == nil {
+		s.Agents = map[string]TokenUsage{}
+	}
+
+	s.Total = s.Total.Add(usage)
+	s.Daily[date] = s.Daily[date].Add(usage)
+	s.Agents[agent] = s.Agents[agent].Add(usage)
+
+	if len(s.Daily) > maxDailyUsageEntries {
+		dates := slices.Sorted(maps.Keys(s.Daily))
+
+		for _, d := range dates[:len(dates)-maxDailyUsageEntries] {
+			delete(s.Daily, d)
+		}
+	}
+
+	if requestNum > 0 {
+		if i := len(s.Requests) - 1; i >= 0 && s.Requests[i].RequestNum == requestNum {
+			s.Requests[i].TokenUsage = s.Requests[i].TokenUsage.Add(usage)
+		} else {
+			s.Requests = append(s.Requests, RequestTokenUsage{RequestNum: requestNum, TokenUsage: usage})
+
+			if len(s.Requests) > maxRequestUsageEntries {
+				s.Requests = slices.Clone(s.Requests[len(s.Requests)-maxRequestUsageEntries:])
+			}
+		}
+	}
+
+	c.persist()
+}
+
+// TokenUsage returns the conversation's token usage statistics.
+func (c *Conversation) TokenUsage() UsageStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.usage.clone()
+}
+
+// DailyTokenUsage returns the amount of tokens used at the day of the given time.
+func (c *Conversation) DailyTokenUsage(t time.Time) TokenUsage {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.usage.DailyUsage(t)
+}
+
+// RequestTokenUsage returns the amount of tokens used to respond to the given request.
+func (c *Conversation) RequestTokenUsage(requestNum int64) TokenUsage {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i := len(c.usage.Requests) - 1; i >= 0; i-- {
+		if c.usage.Requests[i].RequestNum == requestNum {
+			return c.usage.Requests[i].TokenUsage
+		}
+	}
+
+	return TokenUsage{}
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestConversationTokenUsage(t *testing.T) {
+	testee := NewConversation("fake prompt", 0)
+
+	reqNum := testee.AddUserRequest(llms.TextPart("request 1"))
+	testee.AddTokenUsage(reqNum, "main", TokenUsage{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110})
+	testee.AddTokenUsage(reqNum, "music", TokenUsage{PromptTokens: 50, CompletionTokens: 5, TotalTokens: 55})
+	testee.AddTokenUsage(0, "summarizer", TokenUsage{PromptTokens: 20, CompletionTokens: 2, TotalTokens: 22})
+	testee.Reset()
+
+	reqNum = testee.AddUserRequest(llms.TextPart("request 2"))
+	testee.AddTokenUsage(reqNum, "main", TokenUsage{PromptTokens: 200, CompletionTokens: 20, TotalTokens: 220})
+
+	require.Equal(t, TokenUsage{PromptTokens: 150, CompletionTokens: 15, TotalTokens: 165}, testee.RequestTokenUsage(1), "request 1")
+	require.Equal(t, TokenUsage{PromptTokens: 200, CompletionTokens: 20, TotalTokens: 220}, testee.RequestTokenUsage(reqNum), "request 2")
+	require.Equal(t, 407, testee.DailyTokenUsage(time.Now()).TotalTokens, "daily usage")
+	require.Equal(t, 0, testee.DailyTokenUsage(time.Now().Add(-24*time.Hour)).TotalTokens, "usage of yesterday")
+
+	usage := testee.TokenUsage()
+	require.Equal(t, 407, usage.Total.TotalTokens, "total")
+	require.Equal(t, map[string]TokenUsage{
+		"main":       {PromptTokens: 300, CompletionTokens: 30, TotalTokens: 330},
+		"music":      {PromptTokens: 50, CompletionTokens: 5, TotalTokens: 55},
+		"summarizer": {PromptTokens: 20, CompletionTokens: 2, TotalTokens: 22},
+	}, usage.Agents, "agents")
+	require.Len(t, usage.Requests, 2, "requests")
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/channel"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+	"github.com/tmc/langchaingo/llms"
+)
+
+type transcript struct {
+	ChannelID      string              `json:"channelId"`
+	RequestCounter int64               `json:"requestCounter"`
+	Summary        string              `json:"summary,omitempty"`
+	Messages       []transcriptMessage `json:"messages"`
+}
+
+type transcriptMessage struct {
+	RequestNum  int64                `json:"requestNum"`
+	Time        time.Time            `json:"time"`
+	Role        llms.ChatMessageType `json:"role"`
+	Text        string               `json:"text,omitempty"`
+	Reasoning   string               `json:"reasoning,omitempty"`
+	ToolCalls   []transcriptToolCall `json:"toolCalls,omitempty"`
+	ToolResults []transcriptResult   `json:"toolResults,omitempty"`
+	Attachments []string             `json:"attachments,omitempty"`
+}
+
+type transcriptToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type transcriptResult struct {
+	ToolCallID string `json:"toolCallId"`
+	Name       string `json:"name"`
+	Content    string `json:"content"`
+}
+
+func conversationHandler(channels *channel.Channels) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		channelId := req.PathValue("channelId")
+
+		switch req.Method {
+		case http.MethodGet:
+			state, err := channels.ConversationState(channelId)
+			if err != nil {
+				writeChannelError(w, err)
+				return
+			}
+
+			t := toTranscript(channelId, state)
+
+			if req.URL.Query().Get("format") == "markdown" || strings.Contains(req.Header.Get("Accept"), "text/markdown") {
+				w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+				_, _ = w.Write([]byte(t.Markdown()))
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+
+			err = json.NewEncoder(w).Encode(t)
+			if err != nil {
+				slog.Warn(fmt.Sprintf("failed to write conversation transcript: %s", err))
+			}
+		case http.MethodDelete:
+			err := channels.ResetConversation(channelId)
+			if err != nil {
+				writeChannelError(w, err)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "unsupported HTTP method", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// writeChannelError responds with 404 when the channel does not exist and 500 otherwise.
+func writeChannelError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, channel.ErrNotFound) {
+		status = http.StatusNotFound
+	} else {
+		slog.Error(err.Error())
+	}
+
+	http.Error(w, err.Error(), status)
+}
+
+func toTranscript(channelId string, state model.ConversationState) transcript {
+	messages := make([]transcriptMessage, 0, len(state.Messages)+len(state.Reasoning))
+	reasoning := state.Reasoning
+
+	for _, msg := range state.Messages {
+		// Insert the model's reasoning before the message it preceded
+		for len(reasoning) > 0 && !reasoning[0].Time.After(msg.Time) {
+			messages = append(messages, reasoningMessage(reasoning[0]))
+			reasoning = reasoning[1:]
+		}
+
+		m := transcriptMessage{
+			RequestNum: msg.RequestNum,
+			Time:       msg.Time,
+			Role:       msg.Role,
+		}
+		text := make([]string, 0, 1)
+
+		for _, p := range msg.Parts {
+			switch part := p.(type) {
+			case llms.TextContent:
+				text = append(text, part.Text)
+			case llms.ToolCall:
+				if part.FunctionCall != nil {
+					m.ToolCalls = append(m.ToolCalls, transcriptToolCall{
+						ID:        part.ID,
+						Name:      part.FunctionCall.Name,
+						Arguments: part.FunctionCall.Arguments,
+					})
+				}
+			case llms.ToolCallResponse:
+				m.ToolResults = append(m.ToolResults, transcriptResult{
+					ToolCallID: part.ToolCallID,
+					Name:       part.Name,
+					Content:    part.Content,
+				})
+			case llms.BinaryContent:
+				m.Attachments = append(m.Attachments, part.MIMEType)
+			case llms.ImageURLContent:
+				m.Attachments = append(m.Attachments, "image")
+			default:
+				m.Attachments = append(m.Attachments, fmt.Sprintf("%T", p))
+			}
+		}
+
+		m.Text = strings.Join(text, "")
+		if m.Text == "" {
+			m.Text = msg.Transcription // voice request that was passed to the model as audio
+		}
+
+		messages = append(messages, m)
+	}
+
+	for _, r := range reasoning {
+		messages = append(messages, reasoningMessage(r))
+	}
+
+	return transcript{
+		ChannelID:      channelId,
+		RequestCounter: state.RequestCounter,
+		Summary:        state.Summary,
+		Messages:       messages,
+	}
+}
+
+func reasoningMessage(r model.Reasoning) transcriptMessage {
+	return transcriptMessage{
+		RequestNum: r.RequestNum,
+		Time:       r.Time,
+		Role:       llms.ChatMessageTypeAI,
+		Reasoning:  r.Text,
+	}
+}
+
+func (t *transcript) Markdown() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Conversation %s\n", t.ChannelID)
+
+	if t.Summary != "" {
+		fmt.Fprintf(&b, "\n## Summary of earlier requests\n\n%s\n", t.Summary)
+	}
+
+	for _, m := range t.Messages {
+		fmt.Fprintf(&b, "\n## %s (request %d, %s)\n", m.Role, m.RequestNum, m.Time.Format(time.DateTime))
+
+		if m.Reasoning != "" {
+			fmt.Fprintf(&b, "\nReasoning:\n> %s\n", strings.ReplaceAll(strings.TrimSpace(m.Reasoning), "\n", "\n> "))
+		}
+
+		if m.Text != "" {
+			fmt.Fprintf(&b, "\n%s\n", strings.TrimSpace(m.Text))
+		}
+
+		for _, c := range m.ToolCalls {
+			fmt.Fprintf(&b, "\nTool call `%s` (%s):\n```json\n%s\n```\n", c.Name, c.ID, c.Arguments)
+		}
+
+		for _, r := range m.ToolResults {
+			fmt.Fprintf(&b, "\nResult of tool call `%s` (%s):\n```\n%s\n```\n", r.Name, r.ToolCallID, strings.TrimSpace(r.Content))
+		}
+
+		for _, a := range m.Attachments {
+			fmt.Fprintf(&b, "\nAttachment: %s\n", a)
+		}
+	}
+
+	return b.String()
+}
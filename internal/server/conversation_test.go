@@ -0,0 +1,241 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/channel"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/storage"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/tools/mcp"
+	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func newConversationTestServer(t *testing.T) *httptest.Server {
+	store, err := storage.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	conv := model.NewConversation("You are a helpful assistant.", 0)
+
+	err = conv.PersistTo("kitchen", store)
+	require.NoError(t, err)
+
+	reqNum := conv.AddUserRequest(llms.TextPart("Computer, what's the weather like?"))
+	conv.AddReasoning(reqNum, "The user wants to know the weather.")
+	conv.AddToolCallResponse(reqNum, llms.ToolCall{
+		ID:           "call-weather",
+		Type:         "function",
+		FunctionCall: &llms.FunctionCall{Name: "get_weather", Arguments: `{"city":"Berlin"}`},
+	}, "sunny, 21°C")
+	conv.AddAIResponse(reqNum, "It is sunny.")
+	conv.AddTokenUsage(reqNum, "main", model.TokenUsage{PromptTokens: 300, CompletionTokens: 20, TotalTokens: 320})
+	conv.Flush()
+
+	channels := channel.NewChannels(context.Background(), config.Configuration{Budget: config.BudgetPolicy{DailyTokens: 1000}}, mcp.Servers{}, nil, store)
+	mux := http.NewServeMux()
+	mux.Handle("/channels/{channelId}/conversation", conversationHandler(channels))
+	mux.Handle("/channels/{channelId}/usage", usageHandler(channels))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestConversationHandlerJSON(t *testing.T) {
+	server := newConversationTestServer(t)
+
+	resp, err := http.Get(server.URL + "/channels/kitchen/conversation")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode, "status")
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"), "content type")
+
+	var actual transcript
+
+	err = json.NewDecoder(resp.Body).Decode(&actual)
+	require.NoError(t, err)
+	require.Equal(t, "kitchen", actual.ChannelID, "channel")
+	require.Equal(t, int64(1), actual.RequestCounter, "request counter")
+
+	type msg struct {
+		Role      llms.ChatMessageType
+		Text      string
+		Reasoning string
+		ToolCalls int
+		Results   int
+	}
+
+	messages := make([]msg, len(actual.Messages))
+	for i, m := range actual.Messages {
+		messages[i] = msg{Role: m.Role, Text: m.Text, Reasoning: m.Reasoning, ToolCalls: len(m.ToolCalls), Results: len(m.ToolResults)}
+	}
+
+	require.Equal(t, []msg{
+		{Role: llms.ChatMessageTypeSystem, Text: "You are a helpful assistant."},
+		{Role: llms.ChatMessageTypeHuman, Text: "Computer, what's the weather like?"},
+		{Role: llms.ChatMessageTypeAI, Reasoning: "The user wants to know the weather."},
+		{Role: llms.ChatMessageTypeAI, ToolCalls: 1},
+		{Role: llms.ChatMessageTypeTool, Results: 1},
+		{Role: llms.ChatMessageTypeAI, Text: "It is sunny."},
+	}, messages, "messages")
+}
+
+func TestConversationHandlerMarkdown(t *testing.T) {
+	server := newConversationTestServer(t)
+
+	for _, c := range []struct {
+		name   string
+		query  string
+		accept string
+	}{
+		{name: "format query parameter", query: "?format=markdown"},
+		{name: "accept header", accept: "text/markdown"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/channels/kitchen/conversation"+c.query, nil)
+			require.NoError(t, err)
+
+			if c.accept != "" {
+				req.Header.Set("Accept", c.accept)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body := readBody(t, resp)
+
+			require.Equal(t, http.StatusOK, resp.StatusCode, "status")
+			require.Equal(t, "text/markdown; charset=utf-8", resp.Header.Get("Content-Type"), "content type")
+			require.Contains(t, body, "# Conversation kitchen\n")
+			require.Contains(t, body, "\nComputer, what's the weather like?\n")
+			require.Contains(t, body, "\nReasoning:\n> The user wants to know the weather.\n")
+			require.Contains(t, body, "\nTool call `get_weather` (call-weather):\n```json\n{\"city\":\"Berlin\"}\n```\n")
+			require.Contains(t, body, "\nResult of tool call `get_weather` (call-weather):\n```\nsunny, 21°C\n```\n")
+			require.Contains(t, body, "\nIt is sunny.\n")
+		})
+	}
+}
+
+func TestConversationHandlerUnknownChannel(t *testing.T) {
+	server := newConversationTestServer(t)
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		req, err := http.NewRequest(method, server.URL+"/channels/unknown/conversation", nil)
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Equal(t, http.StatusNotFound, resp.StatusCode, method)
+	}
+}
+
+func TestConversationHandlerDelete(t *testing.T) {
+	server := newConversationTestServer(t)
+
+	req, err := http.NewRequest(http.MethodDelete, server.URL+"/channels/kitchen/conversation", nil)
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusNoContent, resp.StatusCode, "status")
+
+	resp, err = http.Get(server.URL + "/channels/kitchen/conversation")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var actual transcript
+
+	err = json.NewDecoder(resp.Body).Decode(&actual)
+	require.NoError(t, err)
+	require.Len(t, actual.Messages, 1, "messages after reset")
+	require.Equal(t, llms.ChatMessageTypeSystem, actual.Messages[0].Role, "role")
+	require.Equal(t, "You are a helpful assistant.", actual.Messages[0].Text, "system prompt")
+
+	resp, err = http.Get(server.URL + "/channels/kitchen/usage")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var usage usageReport
+
+	err = json.NewDecoder(resp.Body).Decode(&usage)
+	require.NoError(t, err)
+	require.Equal(t, 320, usage.Today.TotalTokens, "token usage after reset")
+	require.NotNil(t, usage.RemainingTokens, "remaining tokens")
+	require.Equal(t, 680, *usage.RemainingTokens, "remaining tokens after reset")
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	b := new(strings.Builder)
+
+	_, err := io.Copy(b, resp.Body)
+	require.NoError(t, err)
+
+	return b.String()
+}
+
+// countingStore counts the saves of the conversations it delegates to.
+type countingStore struct {
+	model.ConversationStore
+	saves atomic.Int32
+}
+
+func (s *countingStore) Save(id string, state model.ConversationState) error {
+	s.saves.Add(1)
+	return s.ConversationStore.Save(id, state)
+}
+
+func TestConversationHandlerGetDoesNotPersist(t *testing.T) {
+	fileStore, err := storage.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	conv := model.NewConversation("You are a helpful assistant.", 0)
+
+	err = conv.PersistTo("kitchen", fileStore)
+	require.NoError(t, err)
+
+	store := &countingStore{ConversationStore: fileStore}
+	channels := channel.NewChannels(context.Background(), config.Configuration{}, mcp.Servers{}, nil, store)
+	mux := http.NewServeMux()
+	mux.Handle("/channels/{channelId}/conversation", conversationHandler(channels))
+	mux.Handle("/channels/{channelId}/usage", usageHandler(channels))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	for _, path := range []string{"/channels/kitchen/conversation", "/channels/kitchen/usage"} {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode, "%s status", path)
+	}
+
+	require.Equal(t, int32(0), store.saves.Load(), "saves")
+}
+
+func TestToTranscriptVoiceRequest(t *testing.T) {
+	conv := model.NewConversation("You are a helpful assistant.", 0)
+	reqNum := conv.AddVoiceRequest("Computer, how are you?", llms.BinaryPart("audio/wav", []byte("wav")))
+	conv.AddAIResponse(reqNum, "I am fine.")
+
+	actual := toTranscript("kitchen", conv.State())
+
+	require.Len(t, actual.Messages, 3, "messages")
+	require.Equal(t, "Computer, how are you?", actual.Messages[1].Text, "user text")
+	require.Equal(t, []string{"audio/wav"}, actual.Messages[1].Attachments, "attachments")
+}
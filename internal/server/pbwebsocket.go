@@ -6,6 +6,7 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/coder/websocket"
 	"github.com/mgoltzsche/ai-assistant-vui/internal/channel"
@@ -28,7 +29,7 @@ func WebsocketHandler(c *channel.Channel) http.Handler {
 		defer ws.CloseNow()
 
 		go func() {
-			err := readWebsocketMessage(req.Context(), ws, c)
+			err := readWebsocketMessage(req.Context(), ws, requestLocale(req), c)
 			if err != nil {
 				slog.Error("failed to read websocket message", "err", err)
 				return
@@ -42,7 +43,12 @@ func WebsocketHandler(c *channel.Channel) http.Handler {
 	})
 }
 
-func readWebsocketMessage(ctx context.Context, ws *websocket.Conn, out channel.Publisher) error {
+// maxImageSize is the maximum size of an image attachment in bytes.
+const maxImageSize = 10 << 20
+
+func readWebsocketMessage(ctx context.Context, ws *websocket.Conn, locale string, out channel.Publisher) error {
+	requests := &voiceRequestBuilder{locale: locale}
+
 	for {
 		ws.SetReadLimit(-1)
 		msgType, reader, err := ws.Reader(ctx)
@@ -65,12 +71,55 @@ func readWebsocketMessage(ctx context.Context, ws *websocket.Conn, out channel.P
 			return err
 		}
 
-		slog.Info("received pb message", "len", len(pbMsg.GetAudioMessage()), "text", pbMsg.GetTextMessage())
+		slog.Info("received pb message", "len", len(pbMsg.GetAudioMessage()), "text", pbMsg.GetTextMessage(), "images", len(pbMsg.GetImages()))
+
+		if req, ok := requests.add(&pbMsg); ok {
+			out.Publish(req)
+		}
+	}
+}
+
+// voiceRequestBuilder creates voice requests from the messages received from a client.
+// Images that were sent without audio are attached to the next voice request.
+type voiceRequestBuilder struct {
+	locale        string
+	pendingImages []model.Image
+}
+
+// add returns the voice request to publish, if the message contains audio.
+func (b *voiceRequestBuilder) add(pbMsg *chat.Message) (model.AudioMessage, bool) {
+	for _, img := range pbMsg.GetImages() {
+		if !strings.HasPrefix(img.GetMimeType(), "image/") || len(img.GetData()) == 0 {
+			slog.Warn(fmt.Sprintf("ignoring invalid image attachment with MIME type %q", img.GetMimeType()))
+			continue
+		}
+
+		if len(img.GetData()) > maxImageSize {
+			slog.Warn(fmt.Sprintf("ignoring image attachment of %d bytes since it exceeds the maximum size of %d bytes", len(img.GetData()), maxImageSize))
+			continue
+		}
 
-		out.Publish(model.AudioMessage{
-			WaveData: pbMsg.GetAudioMessage(),
+		b.pendingImages = model.AppendPendingImages(b.pendingImages, model.Image{
+			MIMEType: img.GetMimeType(),
+			Data:     img.GetData(),
 		})
 	}
+
+	if len(pbMsg.GetAudioMessage()) == 0 {
+		return model.AudioMessage{}, false
+	}
+
+	req := model.AudioMessage{
+		Message: model.Message{
+			Images: b.pendingImages,
+			Locale: b.locale,
+		},
+		WaveData: pbMsg.GetAudioMessage(),
+	}
+
+	b.pendingImages = nil
+
+	return req, true
 }
 
 func streamAgentEvents(ctx context.Context, c channel.Subscriber, ws *websocket.Conn) error {
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/generated/api/chat"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoiceRequestBuilder(t *testing.T) {
+	testee := &voiceRequestBuilder{locale: "de-DE"}
+
+	images := []*chat.Image{
+		pbImage("text/plain", []byte("not an image")),
+		pbImage("image/png", nil),
+		pbImage("image/png", make([]byte, maxImageSize+1)),
+	}
+	for i := range 6 {
+		images = append(images, pbImage("image/png", fmt.Appendf(nil, "image %d", i)))
+	}
+
+	msg := &chat.Message{}
+	msg.SetImages(images)
+
+	_, ok := testee.add(msg)
+	require.False(t, ok, "request published without audio")
+
+	msg = &chat.Message{}
+	msg.SetAudioMessage([]byte("wav 1"))
+
+	req, ok := testee.add(msg)
+	require.True(t, ok, "request published")
+	require.Equal(t, "de-DE", req.Locale, "locale")
+	require.Equal(t, []byte("wav 1"), req.WaveData, "audio")
+
+	expectedImages := make([]model.Image, 0, model.MaxPendingImages)
+	for i := 1; i < 6; i++ {
+		expectedImages = append(expectedImages, model.Image{MIMEType: "image/png", Data: fmt.Appendf(nil, "image %d", i)})
+	}
+	require.Equal(t, expectedImages, req.Images, "images of the request")
+
+	msg = &chat.Message{}
+	msg.SetAudioMessage([]byte("wav 2"))
+
+	req, ok = testee.add(msg)
+	require.True(t, ok, "request published")
+	require.Empty(t, req.Images, "images attached to the next request again")
+}
+
+func pbImage(mimeType string, data []byte) *chat.Image {
+	img := &chat.Image{}
+	img.SetMimeType(mimeType)
+	img.SetData(data)
+
+	return img
+}
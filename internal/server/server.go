@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -17,22 +18,31 @@ import (
 	"github.com/mgoltzsche/ai-assistant-vui/internal/channel"
 	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
 	"github.com/mgoltzsche/ai-assistant-vui/internal/tools/mcp"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/vui"
 	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
 	"github.com/orcaman/writerseeker"
 )
 
-func AddRoutes(ctx context.Context, cfg config.Configuration, mcpServers mcp.Servers, webDir string, mux *http.ServeMux) {
-	channels := channel.NewChannels(ctx, cfg, mcpServers)
+func AddRoutes(ctx context.Context, cfg config.Configuration, mcpServers mcp.Servers, chatBackends *vui.ChatBackends, store model.ConversationStore, webDir string, mux *http.ServeMux) {
+	channels := channel.NewChannels(ctx, cfg, mcpServers, chatBackends, store)
 
 	mux.Handle("/", http.FileServer(http.Dir(webDir)))
 
 	mux.HandleFunc("/channels/{channelId}/audio", func(w http.ResponseWriter, req *http.Request) {
 		channelId := req.PathValue("channelId")
 
-		c, err := channels.GetOrCreate(channelId)
+		c, err := channels.GetOrCreate(channelId, req.URL.Query().Get("profile"))
 		if err != nil {
-			slog.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			switch {
+			case errors.Is(err, channel.ErrNotFound):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, channel.ErrProfileConflict):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				slog.Error(err.Error())
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+
 			return
 		}
 
@@ -48,6 +58,8 @@ func AddRoutes(ctx context.Context, cfg config.Configuration, mcpServers mcp.Ser
 				return
 			}
 
+			audioMsg.Locale = requestLocale(req)
+
 			c.Publish(audioMsg)
 
 			return
@@ -62,6 +74,9 @@ func AddRoutes(ctx context.Context, cfg config.Configuration, mcpServers mcp.Ser
 			http.Error(w, "unsupported HTTP method", http.StatusMethodNotAllowed)
 		}
 	})
+
+	mux.Handle("/channels/{channelId}/conversation", conversationHandler(channels))
+	mux.Handle("/channels/{channelId}/usage", usageHandler(channels))
 }
 
 func readWaveAudio(reader io.Reader) (audio.Buffer, error) {
@@ -141,6 +156,23 @@ func sendHTTPAudioStream(c pubsubChannel, w http.ResponseWriter, req *http.Reque
 	}
 }
 
+// requestLocale returns the locale the client specified using the locale query parameter or the Accept-Language header.
+func requestLocale(req *http.Request) string {
+	locale := req.URL.Query().Get("locale")
+	if locale == "" {
+		// e.g. "de-DE,de;q=0.9,en;q=0.8"
+		locale, _, _ = strings.Cut(req.Header.Get("Accept-Language"), ",")
+		locale, _, _ = strings.Cut(locale, ";")
+	}
+
+	locale = strings.TrimSpace(locale)
+	if locale == "*" {
+		return ""
+	}
+
+	return locale
+}
+
 func toAudioMessage(reader io.Reader) (model.AudioMessage, error) {
 	audioData, err := io.ReadAll(reader)
 	if err != nil {
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/channel"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+)
+
+type usageReport struct {
+	ChannelID        string           `json:"channelId"`
+	Today            model.TokenUsage `json:"today"`
+	DailyTokenBudget int              `json:"dailyTokenBudget,omitempty"`
+	RemainingTokens  *int             `json:"remainingTokens,omitempty"`
+	model.UsageStats
+}
+
+func usageHandler(channels *channel.Channels) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "unsupported HTTP method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		channelId := req.PathValue("channelId")
+
+		state, err := channels.ConversationState(channelId)
+		if err != nil {
+			writeChannelError(w, err)
+			return
+		}
+
+		// Profiles may override the budget
+		dailyTokenBudget, err := channels.DailyTokenBudget(channelId)
+		if err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		report := usageReport{
+			ChannelID:        channelId,
+			Today:            state.Usage.DailyUsage(time.Now()),
+			DailyTokenBudget: dailyTokenBudget,
+			UsageStats:       state.Usage,
+		}
+
+		if dailyTokenBudget > 0 {
+			remaining := max(dailyTokenBudget-report.Today.TotalTokens, 0)
+			report.RemainingTokens = &remaining
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(w).Encode(report)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("failed to write token usage: %s", err))
+		}
+	})
+}
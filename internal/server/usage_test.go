@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/channel"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/storage"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/tools/mcp"
+	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestUsageHandlerProfileBudget(t *testing.T) {
+	store, err := storage.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	for _, channelID := range []string{"kitchen", "kids-room"} {
+		conv := model.NewConversation("You are a helpful assistant.", 0)
+
+		err = conv.PersistTo(channelID, store)
+		require.NoError(t, err)
+
+		reqNum := conv.AddUserRequest(llms.TextPart("Computer, tell me a joke."))
+		conv.AddTokenUsage(reqNum, "main", model.TokenUsage{PromptTokens: 90, CompletionTokens: 10, TotalTokens: 100})
+		conv.Flush()
+	}
+
+	cfg := config.Configuration{
+		Budget: config.BudgetPolicy{DailyTokens: 1000},
+		Profiles: map[string]config.Profile{
+			"kids": {"budget": map[string]any{"dailyTokens": 300}},
+		},
+		ChannelProfiles: map[string]string{"kids-room": "kids"},
+	}
+	channels := channel.NewChannels(context.Background(), cfg, mcp.Servers{}, nil, store)
+	mux := http.NewServeMux()
+	mux.Handle("/channels/{channelId}/usage", usageHandler(channels))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	for _, tc := range []struct {
+		channelID         string
+		expectedBudget    int
+		expectedRemaining int
+	}{
+		{channelID: "kitchen", expectedBudget: 1000, expectedRemaining: 900},
+		{channelID: "kids-room", expectedBudget: 300, expectedRemaining: 200},
+	} {
+		t.Run(tc.channelID, func(t *testing.T) {
+			resp, err := http.Get(server.URL + "/channels/" + tc.channelID + "/usage")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusOK, resp.StatusCode, "status")
+
+			var actual usageReport
+
+			err = json.NewDecoder(resp.Body).Decode(&actual)
+			require.NoError(t, err)
+			require.Equal(t, 100, actual.Today.TotalTokens, "today")
+			require.Equal(t, tc.expectedBudget, actual.DailyTokenBudget, "budget")
+			require.NotNil(t, actual.RemainingTokens, "remaining tokens")
+			require.Equal(t, tc.expectedRemaining, *actual.RemainingTokens, "remaining tokens")
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+)
+
+var _ model.ConversationStore = &FileStore{}
+
+// FileStore persists conversations as JSON files within a directory.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	err := os.MkdirAll(dir, 0o750)
+	if err != nil {
+		return nil, fmt.Errorf("create conversation store directory: %w", err)
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) Load(id string) (model.ConversationState, bool, error) {
+	var state model.ConversationState
+
+	b, err := os.ReadFile(s.file(id))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return state, false, nil
+		}
+
+		return state, false, fmt.Errorf("load conversation %s: %w", id, err)
+	}
+
+	err = json.Unmarshal(b, &state)
+	if err != nil {
+		return state, false, fmt.Errorf("load conversation %s: %w", id, err)
+	}
+
+	return state, true, nil
+}
+
+func (s *FileStore) Save(id string, state model.ConversationState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshal conversation %s: %w", id, err)
+	}
+
+	// Write into a temporary file first and rename it afterwards to avoid corrupting the file on crash.
+	tmpFile, err := os.CreateTemp(s.dir, ".tmp-conversation-")
+	if err != nil {
+		return fmt.Errorf("save conversation %s: %w", id, err)
+	}
+
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(b)
+	if err != nil {
+		_ = tmpFile.Close()
+		return fmt.Errorf("save conversation %s: %w", id, err)
+	}
+
+	err = tmpFile.Close()
+	if err != nil {
+		return fmt.Errorf("save conversation %s: %w", id, err)
+	}
+
+	err = os.Rename(tmpFile.Name(), s.file(id))
+	if err != nil {
+		return fmt.Errorf("save conversation %s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (s *FileStore) file(id string) string {
+	// Escaping the ID prevents path traversal since it is provided by the client.
+	return filepath.Join(s.dir, url.QueryEscape(id)+".json")
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestFileStore(t *testing.T) {
+	testee, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	_, found, err := testee.Load("fake-channel")
+	require.NoError(t, err)
+	require.False(t, found, "found before save")
+
+	conv := model.NewConversation("fake system prompt", 1)
+	err = conv.PersistTo("../fake-channel", testee)
+	require.NoError(t, err)
+
+	reqNum := conv.AddUserRequest(llms.TextPart("Computer, what's the weather?"))
+	conv.AddToolCallResponse(reqNum, llms.ToolCall{
+		ID:   "fake-call-id",
+		Type: "function",
+		FunctionCall: &llms.FunctionCall{
+			Name:      "weather",
+			Arguments: `{"location":"Berlin"}`,
+		},
+	}, "sunny")
+	conv.AddAIResponse(reqNum, "It is sunny.")
+	conv.Flush()
+
+	state, found, err := testee.Load("../fake-channel")
+	require.NoError(t, err)
+	require.True(t, found, "found after save")
+	require.Equal(t, int64(2), state.RequestCounter, "request counter")
+
+	restored, err := model.RestoreConversation(state)
+	require.NoError(t, err)
+	require.Equal(t, conv.Messages(), restored.Messages(), "restored messages")
+	require.Equal(t, "fake system prompt", restored.SystemPrompt(), "system prompt")
+}
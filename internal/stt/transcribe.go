@@ -27,17 +27,49 @@ func (t *Transcriber) Transcribe(ctx context.Context, input <-chan AudioMessage)
 	go func() {
 		defer close(ch)
 
+		for msg := range t.TranscribeAudio(ctx, input) {
+			ch <- msg.Message
+		}
+	}()
+
+	return ch
+}
+
+// TranscribeAudio transcribes the provided speech to text, keeping the audio data along with the transcription.
+func (t *Transcriber) TranscribeAudio(ctx context.Context, input <-chan AudioMessage) <-chan AudioMessage {
+	ch := make(chan AudioMessage, 10)
+
+	go func() {
+		defer close(ch)
+
+		// Images of requests without speech are attached to the next request.
+		var pendingImages []model.Image
+
 		for msg := range input {
 			result, err := t.Service.Transcribe(ctx, msg.WaveData)
 			if err != nil {
 				slog.Error(fmt.Sprintf("transcribe: %s", err))
+
+				pendingImages = model.AppendPendingImages(pendingImages, msg.Images...)
+
 				continue
 			}
 
 			result.Text = strings.TrimSuffix(result.Text, "[BLANK_AUDIO]")
+			result.Locale = msg.Locale
+
+			if strings.TrimSpace(result.Text) == "" {
+				pendingImages = model.AppendPendingImages(pendingImages, msg.Images...)
+
+				continue
+			}
+
+			result.Images = model.AppendPendingImages(pendingImages, msg.Images...)
+			pendingImages = nil
 
-			if strings.TrimSpace(result.Text) != "" {
-				ch <- result
+			ch <- AudioMessage{
+				Message:  result,
+				WaveData: msg.WaveData,
 			}
 		}
 	}()
@@ -2,8 +2,11 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strings"
+	"sync"
 
 	"github.com/tmc/langchaingo/llms"
 )
@@ -12,36 +15,142 @@ type FunctionCallChecker interface {
 	IsFunctionCallAllowed(name string, args map[string]any) (bool, error)
 }
 
+// LoopPolicy specifies how often tools may be called within a request.
+// Zero values are replaced with defaults.
+type LoopPolicy struct {
+	// MaxIdenticalCalls is how often a tool may be called with the same arguments (defaults to 1).
+	MaxIdenticalCalls int
+	// MaxCallsPerTool is how often a tool may be called with any arguments (defaults to 5).
+	MaxCallsPerTool int
+	// MaxCalls overwrites MaxCallsPerTool for particular tools by name.
+	MaxCalls map[string]int
+	// MaxRejections is the number of rejected calls after which a tool is removed from the tool list (defaults to 2).
+	// This is to break loops of LLMs that ignore the rejection reason.
+	MaxRejections int
+}
+
+func (p LoopPolicy) maxIdenticalCalls() int {
+	if p.MaxIdenticalCalls <= 0 {
+		return 1
+	}
+
+	return p.MaxIdenticalCalls
+}
+
+func (p LoopPolicy) maxRejections() int {
+	if p.MaxRejections <= 0 {
+		return 2
+	}
+
+	return p.MaxRejections
+}
+
+func (p LoopPolicy) maxCalls(toolName string) int {
+	if n, ok := p.MaxCalls[toolName]; ok && n > 0 {
+		return n
+	}
+
+	if p.MaxCallsPerTool <= 0 {
+		return 5
+	}
+
+	return p.MaxCallsPerTool
+}
+
+// LoopError is returned when a tool call is rejected because it repeats previous calls.
+type LoopError struct {
+	Tool    string
+	Message string
+	Banned  bool
+}
+
+func (e *LoopError) Error() string {
+	return fmt.Sprintf("rejected call of tool %q: %s", e.Tool, e.Message)
+}
+
+// ToolResult returns the error as structured tool response to let the LLM know why the call was rejected.
+func (e *LoopError) ToolResult() string {
+	hint := "Use the result of the previous call or call the tool with different arguments."
+	if e.Banned {
+		hint = "The tool is not available anymore to answer the current request. Answer with the information you have."
+	}
+
+	b, _ := json.Marshal(map[string]string{
+		"error":   "call_rejected",
+		"tool":    e.Tool,
+		"message": e.Message,
+		"hint":    hint,
+	})
+
+	return string(b)
+}
+
+// CallLoopPreventingProvider detects tool call loops within a request.
+// A call is identified by the tool name and its normalized arguments.
 type CallLoopPreventingProvider struct {
 	tools       []Tool
+	policy      LoopPolicy
 	bannedNames map[string]struct{}
-	calls       map[string]struct{}
+	calls       map[string]int
+	toolCalls   map[string]int
+	rejections  map[string]int
+	mutex       sync.Mutex
 }
 
-func NewCallLoopPreventingProvider(fns []Tool) *CallLoopPreventingProvider {
+func NewCallLoopPreventingProvider(fns []Tool, policy LoopPolicy) *CallLoopPreventingProvider {
 	return &CallLoopPreventingProvider{
 		tools:       fns,
+		policy:      policy,
 		bannedNames: map[string]struct{}{},
-		calls:       map[string]struct{}{},
+		calls:       map[string]int{},
+		toolCalls:   map[string]int{},
+		rejections:  map[string]int{},
 	}
 }
 
-func (p *CallLoopPreventingProvider) IsToolCallAllowed(call *llms.FunctionCall) (bool, error) {
-	callSignature := call.Name
+// CheckToolCall records the tool call and returns a LoopError if it must not be executed.
+func (p *CallLoopPreventingProvider) CheckToolCall(call *llms.FunctionCall) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
 
-	if _, alreadyCalled := p.calls[callSignature]; alreadyCalled {
-		slog.Warn(fmt.Sprintf("disabling %s tool temporarily due to duplicate call", callSignature))
-		p.bannedNames[call.Name] = struct{}{}
+	callSignature := call.Name + normalizeArguments(call.Arguments)
+
+	var loopErr *LoopError
+
+	switch {
+	case p.calls[callSignature] >= p.policy.maxIdenticalCalls():
+		loopErr = &LoopError{
+			Tool:    call.Name,
+			Message: "The tool was already called with the same arguments while answering the current request. Its result is part of the conversation.",
+		}
+	case p.toolCalls[call.Name] >= p.policy.maxCalls(call.Name):
+		loopErr = &LoopError{
+			Tool:    call.Name,
+			Message: fmt.Sprintf("The tool must not be called more than %d times per request.", p.policy.maxCalls(call.Name)),
+		}
+	default:
+		p.calls[callSignature]++
+		p.toolCalls[call.Name]++
 
-		return false, nil
+		return nil
 	}
 
-	p.calls[callSignature] = struct{}{}
+	p.rejections[call.Name]++
+
+	if p.rejections[call.Name] >= p.policy.maxRejections() || p.toolCalls[call.Name] >= p.policy.maxCalls(call.Name) {
+		slog.Warn(fmt.Sprintf("disabling %s tool temporarily due to repeated calls", call.Name))
 
-	return true, nil
+		p.bannedNames[call.Name] = struct{}{}
+		loopErr.Banned = true
+	}
+
+	return loopErr
 }
 
 func (p *CallLoopPreventingProvider) Tools(_ context.Context) ([]Tool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
 	tools := p.tools
 	filtered := make([]Tool, 0, len(tools))
 
@@ -53,3 +162,42 @@ func (p *CallLoopPreventingProvider) Tools(_ context.Context) ([]Tool, error) {
 
 	return filtered, nil
 }
+
+// normalizeArguments returns the arguments in a canonical form in order to detect equal calls.
+// Object keys are sorted, whitespace is collapsed and strings are compared case-insensitively.
+func normalizeArguments(args string) string {
+	var v any
+
+	err := json.Unmarshal([]byte(args), &v)
+	if err != nil {
+		return strings.Join(strings.Fields(strings.ToLower(args)), " ")
+	}
+
+	b, err := json.Marshal(normalizeValue(v))
+	if err != nil {
+		return args
+	}
+
+	return string(b)
+}
+
+func normalizeValue(v any) any {
+	switch val := v.(type) {
+	case string:
+		return strings.Join(strings.Fields(strings.ToLower(val)), " ")
+	case []any:
+		for i, item := range val {
+			val[i] = normalizeValue(item)
+		}
+
+		return val
+	case map[string]any:
+		for k, item := range val {
+			val[k] = normalizeValue(item)
+		}
+
+		return val
+	default:
+		return v
+	}
+}
@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+type fakeTool string
+
+func (t fakeTool) Definition() llms.FunctionDefinition {
+	return llms.FunctionDefinition{Name: string(t)}
+}
+
+func (t fakeTool) Call(_ context.Context, _ string) (string, error) {
+	return "", nil
+}
+
+func TestCallLoopPreventingProvider(t *testing.T) {
+	type call struct {
+		name    string
+		args    string
+		allowed bool
+	}
+
+	for _, c := range []struct {
+		name         string
+		policy       LoopPolicy
+		calls        []call
+		expectBanned []string
+	}{
+		{
+			name: "allow calls with different arguments",
+			calls: []call{
+				{"set_volume", `{"volume":30}`, true},
+				{"set_volume", `{"volume":50}`, true},
+				{"wikipedia", `{"query":"Berlin"}`, true},
+				{"wikipedia", `{"query":"Paris"}`, true},
+			},
+		},
+		{
+			name: "reject identical calls",
+			calls: []call{
+				{"wikipedia", `{"query":"Berlin","lang":"en"}`, true},
+				{"wikipedia", `{ "lang": "en", "query": "berlin " }`, false},
+				{"wikipedia", `{"query":"Paris"}`, true},
+			},
+		},
+		{
+			name: "ban tool after repeated rejections",
+			calls: []call{
+				{"wikipedia", `{"query":"Berlin"}`, true},
+				{"wikipedia", `{"query":"Berlin"}`, false},
+				{"wikipedia", `{"query":"Berlin"}`, false},
+			},
+			expectBanned: []string{"wikipedia"},
+		},
+		{
+			name:   "ban tool after configured rejections",
+			policy: LoopPolicy{MaxRejections: 3},
+			calls: []call{
+				{"wikipedia", `{"query":"Berlin"}`, true},
+				{"wikipedia", `{"query":"Berlin"}`, false},
+				{"wikipedia", `{"query":"Berlin"}`, false},
+				{"wikipedia", `{"query":"Paris"}`, true},
+				{"wikipedia", `{"query":"Paris"}`, false},
+			},
+			expectBanned: []string{"wikipedia"},
+		},
+		{
+			name:   "allow configured identical calls",
+			policy: LoopPolicy{MaxIdenticalCalls: 2},
+			calls: []call{
+				{"set_volume", `{"volume":30}`, true},
+				{"set_volume", `{"volume":30}`, true},
+				{"set_volume", `{"volume":30}`, false},
+			},
+		},
+		{
+			name:   "limit calls per tool",
+			policy: LoopPolicy{MaxCallsPerTool: 3, MaxCalls: map[string]int{"set_volume": 2}},
+			calls: []call{
+				{"set_volume", `{"volume":30}`, true},
+				{"set_volume", `{"volume":40}`, true},
+				{"set_volume", `{"volume":50}`, false},
+				{"wikipedia", `{"query":"Berlin"}`, true},
+				{"wikipedia", `{"query":"Paris"}`, true},
+				{"wikipedia", `{"query":"Rome"}`, true},
+			},
+			expectBanned: []string{"set_volume"},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			testee := NewCallLoopPreventingProvider([]Tool{fakeTool("set_volume"), fakeTool("wikipedia")}, c.policy)
+
+			for i, call := range c.calls {
+				err := testee.CheckToolCall(&llms.FunctionCall{Name: call.name, Arguments: call.args})
+				if call.allowed {
+					require.NoError(t, err, "call %d", i)
+				} else {
+					var loopErr *LoopError
+					require.True(t, errors.As(err, &loopErr), "call %d should be rejected with a LoopError but returned %v", i, err)
+				}
+			}
+
+			tools, err := testee.Tools(context.Background())
+			require.NoError(t, err)
+
+			available := map[string]bool{}
+			for _, tool := range tools {
+				available[tool.Definition().Name] = true
+			}
+
+			for _, name := range c.expectBanned {
+				require.False(t, available[name], "tool %s should be banned", name)
+			}
+
+			require.Len(t, tools, 2-len(c.expectBanned), "available tools")
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxResourceTextLength is the maximum amount of bytes of a resource's text that is passed to the LLM.
+const maxResourceTextLength = 8000
+
+// resourceReader reads the contents of a resource, e.g. mcp.ClientSession.
+type resourceReader interface {
+	ReadResource(ctx context.Context, params *mcp.ReadResourceParams) (*mcp.ReadResourceResult, error)
+}
+
+// toToolResult converts the content of an MCP tool call result.
+// Text, fetched resources and structured content are returned as text while images and audio clips become attachments.
+func toToolResult(ctx context.Context, result *mcp.CallToolResult, resources resourceReader) (tools.Result, error) {
+	r := &toolResultBuilder{}
+
+	for _, c := range result.Content {
+		switch content := c.(type) {
+		case *mcp.TextContent:
+			r.text = append(r.text, content.Text)
+		case *mcp.ImageContent:
+			r.attach(content.MIMEType, content.Data, "image")
+		case *mcp.AudioContent:
+			r.attach(content.MIMEType, content.Data, "audio clip")
+		case *mcp.EmbeddedResource:
+			if content.Resource != nil {
+				r.addResource(content.Resource)
+			}
+		case *mcp.ResourceLink:
+			res, err := resources.ReadResource(ctx, &mcp.ReadResourceParams{URI: content.URI})
+			if err != nil {
+				slog.Warn(fmt.Sprintf("failed to read linked resource %s: %s", content.URI, err))
+				r.text = append(r.text, fmt.Sprintf("[Resource %s: %s %s]", content.URI, content.Name, content.Description))
+
+				continue
+			}
+
+			for _, c := range res.Contents {
+				r.addResource(c)
+			}
+		default:
+			return tools.Result{}, fmt.Errorf("unsupported content of type %T", c)
+		}
+	}
+
+	if result.StructuredContent != nil {
+		err := r.addStructuredContent(result.StructuredContent)
+		if err != nil {
+			return tools.Result{}, err
+		}
+	}
+
+	return tools.Result{
+		Text:        strings.Join(r.text, ""),
+		Attachments: r.attachments,
+	}, nil
+}
+
+type toolResultBuilder struct {
+	text        []string
+	attachments []tools.Attachment
+}
+
+func (r *toolResultBuilder) attach(mimeType string, data []byte, kind string) {
+	r.attachments = append(r.attachments, tools.Attachment{MIMEType: mimeType, Data: data})
+	r.text = append(r.text, fmt.Sprintf("[%s %s attached]", mimeType, kind))
+}
+
+// addResource adds the text of the given resource or attaches it if it is an image or audio clip.
+// Other binary resources are only described since the LLM cannot process them.
+func (r *toolResultBuilder) addResource(res *mcp.ResourceContents) {
+	switch {
+	case res.Blob == nil:
+		text := res.Text
+		if len(text) > maxResourceTextLength {
+			text = truncateText(text, maxResourceTextLength) + "... (truncated)"
+		}
+
+		r.text = append(r.text, text)
+	case strings.HasPrefix(res.MIMEType, "image/"):
+		r.attach(res.MIMEType, res.Blob, "image")
+	case strings.HasPrefix(res.MIMEType, "audio/"):
+		r.attach(res.MIMEType, res.Blob, "audio clip")
+	default:
+		r.text = append(r.text, fmt.Sprintf("[Resource %s: %s, %d bytes]", res.URI, res.MIMEType, len(res.Blob)))
+	}
+}
+
+// truncateText returns the first n bytes of the given text without splitting a multi-byte character.
+func truncateText(text string, n int) string {
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+
+	return text[:n]
+}
+
+// addStructuredContent passes the structured content through as JSON unless the text contains it already.
+func (r *toolResultBuilder) addStructuredContent(content any) error {
+	b, err := json.Marshal(content)
+	if err != nil {
+		return fmt.Errorf("marshal structured content: %w", err)
+	}
+
+	text := strings.Join(r.text, "")
+
+	var textContent any
+	if json.Unmarshal([]byte(text), &textContent) == nil {
+		normalized, err := json.Marshal(textContent)
+		if err == nil && bytes.Equal(normalized, b) {
+			return nil // the text is the backward compatible representation of the structured content
+		}
+	}
+
+	if text != "" {
+		r.text = append(r.text, "\n")
+	}
+
+	r.text = append(r.text, string(b))
+
+	return nil
+}
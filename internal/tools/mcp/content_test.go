@@ -0,0 +1,100 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResourceReader map[string]*mcp.ResourceContents
+
+func (r fakeResourceReader) ReadResource(_ context.Context, params *mcp.ReadResourceParams) (*mcp.ReadResourceResult, error) {
+	res, ok := r[params.URI]
+	if !ok {
+		return nil, errors.New("resource not found")
+	}
+
+	return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{res}}, nil
+}
+
+func TestToToolResult(t *testing.T) {
+	resources := fakeResourceReader{
+		"file:///notes.txt": {URI: "file:///notes.txt", MIMEType: "text/plain", Text: "Buy milk."},
+	}
+
+	for _, c := range []struct {
+		name              string
+		result            *mcp.CallToolResult
+		expectText        string
+		expectAttachments []tools.Attachment
+	}{
+		{
+			name:       "text",
+			result:     &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "It is sunny."}}},
+			expectText: "It is sunny.",
+		},
+		{
+			name: "image and audio",
+			result: &mcp.CallToolResult{Content: []mcp.Content{
+				&mcp.ImageContent{MIMEType: "image/png", Data: []byte("png")},
+				&mcp.AudioContent{MIMEType: "audio/wav", Data: []byte("wav")},
+			}},
+			expectText: "[image/png image attached][audio/wav audio clip attached]",
+			expectAttachments: []tools.Attachment{
+				{MIMEType: "image/png", Data: []byte("png")},
+				{MIMEType: "audio/wav", Data: []byte("wav")},
+			},
+		},
+		{
+			name: "embedded resources",
+			result: &mcp.CallToolResult{Content: []mcp.Content{
+				&mcp.EmbeddedResource{Resource: &mcp.ResourceContents{URI: "file:///a.txt", Text: "Hello. "}},
+				&mcp.EmbeddedResource{Resource: &mcp.ResourceContents{URI: "file:///a.pdf", MIMEType: "application/pdf", Blob: []byte("pdf")}},
+			}},
+			expectText: "Hello. [Resource file:///a.pdf: application/pdf, 3 bytes]",
+		},
+		{
+			name: "truncate resource text on character boundary",
+			result: &mcp.CallToolResult{Content: []mcp.Content{
+				&mcp.EmbeddedResource{Resource: &mcp.ResourceContents{URI: "file:///a.txt", Text: strings.Repeat("a", maxResourceTextLength-1) + "äbc"}},
+			}},
+			expectText: strings.Repeat("a", maxResourceTextLength-1) + "... (truncated)",
+		},
+		{
+			name: "resource links",
+			result: &mcp.CallToolResult{Content: []mcp.Content{
+				&mcp.ResourceLink{URI: "file:///notes.txt", Name: "notes"},
+				&mcp.ResourceLink{URI: "file:///missing.txt", Name: "missing", Description: "A missing file."},
+			}},
+			expectText: "Buy milk.[Resource file:///missing.txt: missing A missing file.]",
+		},
+		{
+			name: "structured content",
+			result: &mcp.CallToolResult{
+				Content:           []mcp.Content{&mcp.TextContent{Text: "The temperature is 21°C."}},
+				StructuredContent: map[string]any{"temperature": 21},
+			},
+			expectText: "The temperature is 21°C.\n{\"temperature\":21}",
+		},
+		{
+			name: "structured content with JSON text",
+			result: &mcp.CallToolResult{
+				Content:           []mcp.Content{&mcp.TextContent{Text: `{ "temperature": 21 }`}},
+				StructuredContent: map[string]any{"temperature": 21},
+			},
+			expectText: `{ "temperature": 21 }`,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := toToolResult(context.Background(), c.result, resources)
+			require.NoError(t, err)
+			require.Equal(t, c.expectText, actual.Text, "text")
+			require.Equal(t, c.expectAttachments, actual.Attachments, "attachments")
+		})
+	}
+}
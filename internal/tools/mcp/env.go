@@ -0,0 +1,107 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
+)
+
+// processEnv returns the environment of an MCP server process.
+// It extends the assistant's environment with the variables of the env files, the env map and the secret files,
+// in that order of precedence.
+// Errors never contain variable values since those may be secrets.
+func processEnv(s config.MCPServer) ([]string, error) {
+	env := map[string]string{}
+
+	for _, file := range s.EnvFrom {
+		err := readEnvFile(file, env)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(s.Env)) {
+		v, err := expandEnvRefs(s.Env[k])
+		if err != nil {
+			return nil, fmt.Errorf("env %s: %w", k, err)
+		}
+
+		env[k] = v
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(s.SecretFiles)) {
+		b, err := os.ReadFile(s.SecretFiles[k])
+		if err != nil {
+			return nil, fmt.Errorf("read secret file for env var %s: %w", k, err)
+		}
+
+		env[k] = strings.TrimRight(string(b), "\r\n")
+	}
+
+	result := os.Environ()
+	for _, k := range slices.Sorted(maps.Keys(env)) {
+		result = append(result, fmt.Sprintf("%s=%s", k, env[k]))
+	}
+
+	return result, nil
+}
+
+// readEnvFile reads the KEY=VALUE lines of the given file into the given map.
+func readEnvFile(file string, env map[string]string) error {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("read env file: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		k, v, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		k = strings.TrimSpace(k)
+
+		if !ok || k == "" {
+			return fmt.Errorf("env file %s: line %d: expected KEY=VALUE", file, i)
+		}
+
+		v = strings.TrimSpace(v)
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+
+		env[k] = v
+	}
+
+	return scanner.Err()
+}
+
+var envRefRegex = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// expandEnvRefs replaces ${VAR} references with the values of the assistant's environment variables.
+func expandEnvRefs(value string) (string, error) {
+	var err error
+
+	expanded := envRefRegex.ReplaceAllStringFunc(value, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+
+		v, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("referenced environment variable %s is not set", name)
+		}
+
+		return v
+	})
+
+	return expanded, err
+}
@@ -0,0 +1,51 @@
+package mcp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessEnv(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, "server.env")
+	secretFile := filepath.Join(dir, "token")
+	err := os.WriteFile(envFile, []byte("# comment\nexport FROM_FILE=a\nQUOTED=\"b c\"\nOVERRIDDEN=file\n"), 0o600)
+	require.NoError(t, err)
+	err = os.WriteFile(secretFile, []byte("secret-token\n"), 0o600)
+	require.NoError(t, err)
+
+	t.Setenv("VUI_TEST_HOST", "home")
+
+	env, err := processEnv(config.MCPServer{
+		EnvFrom: []string{envFile},
+		Env: map[string]string{
+			"OVERRIDDEN": "env",
+			"URL":        "http://${VUI_TEST_HOST}:8000",
+		},
+		SecretFiles: map[string]string{"TOKEN": secretFile},
+	})
+	require.NoError(t, err)
+
+	for _, expected := range []string{"FROM_FILE=a", "QUOTED=b c", "OVERRIDDEN=env", "URL=http://home:8000", "TOKEN=secret-token", "VUI_TEST_HOST=home"} {
+		require.Contains(t, env, expected)
+	}
+
+	require.NotContains(t, env, "OVERRIDDEN=file")
+}
+
+func TestProcessEnvErrorsDoNotLeakValues(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "server.env")
+	err := os.WriteFile(envFile, []byte("TOKEN=secret-token\nsecret-value\n"), 0o600)
+	require.NoError(t, err)
+
+	_, err = processEnv(config.MCPServer{EnvFrom: []string{envFile}})
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "secret-value")
+
+	_, err = processEnv(config.MCPServer{Env: map[string]string{"TOKEN": "${VUI_TEST_UNDEFINED}"}})
+	require.ErrorContains(t, err, "VUI_TEST_UNDEFINED is not set")
+}
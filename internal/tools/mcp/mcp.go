@@ -7,41 +7,48 @@ import (
 	"io"
 	"log/slog"
 	"maps"
-	"os/exec"
 	"slices"
+	"sync"
 
 	"github.com/mgoltzsche/ai-assistant-vui/internal/tools"
 	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
-func NewServers(ctx context.Context, mcpServers map[string]config.MCPServer) (Servers, error) {
+// ErrDegraded is returned when an MCP server is not available.
+var ErrDegraded = errors.New("mcp server degraded")
+
+// NewServers starts or connects to the given MCP servers and supervises them.
+// Servers that cannot be started are marked degraded and retried in the background.
+func NewServers(ctx context.Context, mcpServers map[string]config.MCPServer, policy SupervisionPolicy) (Servers, error) {
 	providers := Servers(make(map[string]tools.ToolProvider, 1))
 
 	for _, k := range slices.Sorted(maps.Keys(mcpServers)) {
-		slog.Info(fmt.Sprintf("starting %s MCP server", k))
-
 		s := mcpServers[k]
 
-		mcpClient := mcp.NewClient(&mcp.Implementation{Name: "mcp-client", Version: "v1.0.0"}, nil)
-
-		// Connect to MCP server over stdin/stdout.
-		transport := &mcp.CommandTransport{Command: exec.Command(s.Command, s.Args...)}
-		session, err := mcpClient.Connect(ctx, transport, nil)
+		err := validateServer(s)
 		if err != nil {
 			_ = providers.Close()
-			return nil, fmt.Errorf("starting %s MCP server: %w", k, err)
-		}
-		if session.InitializeResult().Capabilities.Tools == nil {
-			_ = session.Close()
-			_ = providers.Close()
-			return nil, errors.New("MCP server does not support tools")
+			return nil, fmt.Errorf("%s MCP server: %w", k, err)
 		}
 
-		providers[k] = &mcpToolProvider{
-			Name:    k,
-			Session: session,
+		p := &mcpToolProvider{
+			Name:   k,
+			server: s,
+			policy: policy,
+			done:   make(chan struct{}),
 		}
+		p.client = mcp.NewClient(&mcp.Implementation{Name: "mcp-client", Version: "v1.0.0"}, &mcp.ClientOptions{
+			KeepAlive: policy.healthCheckInterval(),
+			ToolListChangedHandler: func(context.Context, *mcp.ToolListChangedRequest) {
+				slog.Debug(fmt.Sprintf("%s MCP server tool list changed", p.Name))
+				p.invalidateTools()
+			},
+		})
+
+		p.start(ctx)
+
+		providers[k] = p
 	}
 
 	return providers, nil
@@ -78,21 +85,80 @@ func (s Servers) Get(name string) (tools.ToolProvider, error) {
 
 type mcpToolProvider struct {
 	Name    string
-	Session *mcp.ClientSession
+	server  config.MCPServer
+	policy  SupervisionPolicy
+	client  *mcp.Client
+	session *mcp.ClientSession
+	err     error
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+	mutex   sync.RWMutex
+	// tools caches the session's tools until the server notifies about a changed tool list.
+	tools []tools.Tool
+	// toolsGeneration is incremented whenever the tool cache becomes outdated.
+	toolsGeneration int
+	// refreshing is true while the tools are listed in the background after a changed tool list.
+	refreshing bool
 }
 
 func (p *mcpToolProvider) Close() error {
-	return p.Session.Close()
+	p.cancel()
+
+	p.mutex.RLock()
+	session := p.session
+	p.mutex.RUnlock()
+
+	var err error
+	if session != nil {
+		err = session.Close()
+	}
+
+	<-p.done
+
+	return err
 }
 
 func (p *mcpToolProvider) Tools(ctx context.Context) ([]tools.Tool, error) {
-	// TODO: handle pagination?
+	p.mutex.RLock()
+	session, sessionErr, cached, generation := p.session, p.err, p.tools, p.toolsGeneration
+	p.mutex.RUnlock()
+
+	if session == nil {
+		return nil, fmt.Errorf("mcp server %s: %w: %w", p.Name, ErrDegraded, sessionErr)
+	}
+
+	if cached != nil {
+		slog.Debug(fmt.Sprintf("%s MCP server tool cache hit", p.Name))
+		return cached, nil
+	}
+
+	slog.Debug(fmt.Sprintf("%s MCP server tool cache miss", p.Name))
+
+	toolAdapter, err := p.listTools(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	if p.toolsGeneration == generation && p.tools == nil {
+		// Don't cache tools that were listed before the tool list changed
+		// and don't replace tools that were refreshed meanwhile.
+		p.tools = toolAdapter
+	}
+	p.mutex.Unlock()
+
+	return toolAdapter, nil
+}
+
+func (p *mcpToolProvider) listTools(ctx context.Context, session *mcp.ClientSession) ([]tools.Tool, error) {
+	// The iterator fetches all pages.
 	toolAdapter := make([]tools.Tool, 0, 10)
-	for mcpTool, err := range p.Session.Tools(ctx, nil) {
+	for mcpTool, err := range session.Tools(ctx, nil) {
 		if err != nil {
 			return nil, fmt.Errorf("mcp server %s: %w", p.Name, err)
 		}
-		ta, err := NewMCPTool(*mcpTool, p.Session)
+		ta, err := NewMCPTool(*mcpTool, session)
 		if err != nil {
 			return nil, err
 		}
@@ -101,3 +167,62 @@ func (p *mcpToolProvider) Tools(ctx context.Context) ([]tools.Tool, error) {
 
 	return toolAdapter, nil
 }
+
+// invalidateTools refreshes the tool cache in the background.
+// The cached tools are kept until the refreshed ones are available
+// since servers may also notify about a changed tool list when it did not change,
+// e.g. right after the session was initialized.
+func (p *mcpToolProvider) invalidateTools() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.toolsGeneration++
+
+	if !p.refreshing {
+		p.refreshing = true
+
+		go p.refreshTools()
+	}
+}
+
+func (p *mcpToolProvider) refreshTools() {
+	for {
+		p.mutex.Lock()
+		session, generation := p.session, p.toolsGeneration
+		p.mutex.Unlock()
+
+		var (
+			toolAdapter []tools.Tool
+			err         error
+		)
+
+		if session != nil {
+			toolAdapter, err = p.listTools(p.ctx, session)
+		}
+
+		p.mutex.Lock()
+
+		if p.session != session {
+			// The cache was reset when the session changed.
+			p.refreshing = false
+			p.mutex.Unlock()
+
+			return
+		}
+
+		if p.toolsGeneration == generation {
+			p.tools = toolAdapter // nil when failed, letting the next request list the tools again
+			p.refreshing = false
+			p.mutex.Unlock()
+
+			if err != nil && p.ctx.Err() == nil {
+				slog.Warn(fmt.Sprintf("failed to refresh %s MCP server tools: %s", p.Name, err))
+			}
+
+			return
+		}
+
+		// The tool list changed again while listing the tools.
+		p.mutex.Unlock()
+	}
+}
@@ -0,0 +1,96 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolCache(t *testing.T) {
+	listCalls := atomic.Int32{}
+	server := mcp.NewServer(&mcp.Implementation{Name: "fake", Version: "v0.0.1"}, &mcp.ServerOptions{PageSize: 1})
+	server.AddReceivingMiddleware(func(next mcp.MethodHandler) mcp.MethodHandler {
+		return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+			if method == "tools/list" {
+				listCalls.Add(1)
+			}
+
+			return next(ctx, method, req)
+		}
+	})
+
+	addTool := func(name string) {
+		mcp.AddTool(server, &mcp.Tool{Name: name, Description: "Echoes the text."},
+			func(ctx context.Context, req *mcp.CallToolRequest, in echoInput) (*mcp.CallToolResult, any, error) {
+				return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: in.Text}}}, nil, nil
+			})
+	}
+	addTool("echo1")
+	addTool("echo2")
+
+	srv := httptest.NewServer(mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return server }, nil))
+	defer srv.Close()
+
+	ctx := context.Background()
+	servers, err := NewServers(ctx, map[string]config.MCPServer{"fake": {URL: srv.URL}}, SupervisionPolicy{})
+	require.NoError(t, err)
+	defer servers.Close()
+
+	provider, err := servers.Get("fake")
+	require.NoError(t, err)
+
+	tools, err := provider.Tools(ctx)
+	require.NoError(t, err)
+	require.Len(t, tools, 2, "paginated tools")
+
+	// The server notifies the new session about the tools that were added before it connected.
+	waitForToolListRefresh(t, provider.(*mcpToolProvider), &listCalls)
+
+	listCallsBefore := listCalls.Load()
+
+	tools, err = provider.Tools(ctx)
+	require.NoError(t, err)
+	require.Len(t, tools, 2, "cached tools")
+	require.Equal(t, int32(0), listCalls.Load()-listCallsBefore, "list calls after cache hit")
+
+	addTool("echo3") // notifies the client about the changed tool list
+
+	require.Eventually(t, func() bool {
+		tools, err = provider.Tools(ctx)
+		return err == nil && len(tools) == 3
+	}, 5*time.Second, 20*time.Millisecond, "tools should be refreshed after list changed notification")
+
+	waitForToolListRefresh(t, provider.(*mcpToolProvider), &listCalls)
+
+	listCallsBefore = listCalls.Load()
+
+	tools, err = provider.Tools(ctx)
+	require.NoError(t, err)
+	require.Len(t, tools, 3, "cached refreshed tools")
+	require.Equal(t, int32(0), listCalls.Load()-listCallsBefore, "list calls after refreshed cache hit")
+}
+
+// waitForToolListRefresh waits until the provider has cached the tools and no list changed notification arrived for a while.
+// The server sends list changed notifications 10ms after the tool list changed.
+func waitForToolListRefresh(t *testing.T, p *mcpToolProvider, listCalls *atomic.Int32) {
+	lastListCalls := int32(-1)
+
+	require.Eventually(t, func() bool {
+		p.mutex.RLock()
+		settled := !p.refreshing && p.tools != nil
+		p.mutex.RUnlock()
+
+		n := listCalls.Load()
+		settled = settled && n == lastListCalls
+		lastListCalls = n
+
+		return settled
+	}, 5*time.Second, 50*time.Millisecond, "tool list should be refreshed")
+}
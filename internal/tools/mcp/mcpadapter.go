@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
-	"strings"
 
 	"github.com/mgoltzsche/ai-assistant-vui/internal/tools"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -48,26 +47,36 @@ func (t *MCPToolAdapter) Definition() llms.FunctionDefinition {
 }
 
 func (t *MCPToolAdapter) Call(ctx context.Context, args string) (string, error) {
+	result, err := t.CallRich(ctx, args)
+	if err != nil {
+		return "", err
+	}
+
+	return result.Text, nil
+}
+
+// CallRich calls the tool, returning images and audio clips as attachments.
+func (t *MCPToolAdapter) CallRich(ctx context.Context, args string) (tools.Result, error) {
 	argObj, err := t.convertArgs(args)
 	if err != nil {
-		return "", fmt.Errorf("invalid arguments: %w", err)
+		return tools.Result{}, fmt.Errorf("invalid arguments: %w", err)
 	}
 	result, err := t.session.CallTool(ctx, &mcp.CallToolParams{
 		Name:      t.tool.Name,
 		Arguments: argObj,
 	})
 	if err != nil {
-		return "", err
+		return tools.Result{}, err
 	}
-	msg, err := textContentToString(result.Content)
+	r, err := toToolResult(ctx, result, t.session)
 	if err != nil {
-		return "", fmt.Errorf("read mcp tool call response: %w", err)
+		return tools.Result{}, fmt.Errorf("read mcp tool call response: %w", err)
 	}
 	if result.IsError {
-		return "", fmt.Errorf("tool returned error: %s", msg)
+		return tools.Result{}, fmt.Errorf("tool returned error: %s", r.Text)
 	}
 
-	return msg, nil
+	return r, nil
 }
 
 func (t *MCPToolAdapter) convertArgs(args string) (any, error) {
@@ -94,17 +103,3 @@ func (t *MCPToolAdapter) convertArgs(args string) (any, error) {
 		return nil, fmt.Errorf("unsupported argument type %q", typeName)
 	}
 }
-
-func textContentToString(content []mcp.Content) (string, error) {
-	msg := make([]string, 0, 1)
-	for _, c := range content {
-		switch t := c.(type) {
-		case *mcp.TextContent:
-			msg = append(msg, t.Text)
-		default:
-			// TODO: also support other content types
-			return "", fmt.Errorf("unsupported content of type %T", c)
-		}
-	}
-	return strings.Join(msg, ""), nil
-}
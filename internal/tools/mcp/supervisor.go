@@ -0,0 +1,151 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// SupervisionPolicy specifies how MCP servers are health-checked and restarted.
+// Zero values are replaced with defaults.
+type SupervisionPolicy struct {
+	HealthCheckInterval time.Duration
+	InitialBackoff      time.Duration
+	MaxBackoff          time.Duration
+}
+
+func (p SupervisionPolicy) healthCheckInterval() time.Duration {
+	if p.HealthCheckInterval <= 0 {
+		return 30 * time.Second
+	}
+
+	return p.HealthCheckInterval
+}
+
+func (p SupervisionPolicy) initialBackoff() time.Duration {
+	if p.InitialBackoff <= 0 {
+		return time.Second
+	}
+
+	return p.InitialBackoff
+}
+
+func (p SupervisionPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff <= 0 {
+		return time.Minute
+	}
+
+	return p.MaxBackoff
+}
+
+// start connects to the MCP server and keeps supervising it in the background.
+// The server is marked degraded when the connection fails or is lost and restarted with an exponential backoff.
+func (p *mcpToolProvider) start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.ctx = ctx
+
+	session, err := p.connect(ctx)
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s MCP server is degraded: %s", p.Name, err))
+	}
+
+	p.setSession(session, err)
+
+	go func() {
+		defer close(p.done)
+
+		p.supervise(ctx, session)
+	}()
+}
+
+func (p *mcpToolProvider) supervise(ctx context.Context, session *mcp.ClientSession) {
+	backoff := p.policy.initialBackoff()
+
+	for {
+		if session != nil {
+			connected := time.Now()
+			err := session.Wait()
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err == nil {
+				err = errors.New("connection closed")
+			}
+
+			_ = session.Close()
+			p.setSession(nil, err)
+
+			slog.Warn(fmt.Sprintf("%s MCP server is degraded: %s", p.Name, err))
+
+			if time.Since(connected) > p.policy.maxBackoff() {
+				backoff = p.policy.initialBackoff() // the server was healthy for a while
+			}
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+
+		backoff = min(2*backoff, p.policy.maxBackoff())
+
+		var err error
+
+		session, err = p.connect(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			slog.Warn(fmt.Sprintf("failed to restart %s MCP server, retrying in %s: %s", p.Name, backoff, err))
+			p.setSession(nil, err)
+
+			continue
+		}
+
+		slog.Info(fmt.Sprintf("%s MCP server recovered", p.Name))
+		p.setSession(session, nil)
+	}
+}
+
+func (p *mcpToolProvider) connect(ctx context.Context) (*mcp.ClientSession, error) {
+	if p.server.URL != "" {
+		slog.Info(fmt.Sprintf("connecting to %s MCP server at %s", p.Name, p.server.URL))
+	} else {
+		slog.Info(fmt.Sprintf("starting %s MCP server", p.Name))
+	}
+
+	transport, err := newTransport(p.server)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := p.client.Connect(ctx, transport, nil)
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+
+	if session.InitializeResult().Capabilities.Tools == nil {
+		_ = session.Close()
+		return nil, errors.New("MCP server does not support tools")
+	}
+
+	return session, nil
+}
+
+func (p *mcpToolProvider) setSession(session *mcp.ClientSession, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.session = session
+	p.err = err
+	p.tools = nil
+	p.toolsGeneration++
+}
@@ -0,0 +1,51 @@
+package mcp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSupervisedMCPServerRecovers(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	ctx := context.Background()
+	servers, err := NewServers(ctx, map[string]config.MCPServer{
+		"home": {URL: "http://" + addr},
+	}, SupervisionPolicy{
+		HealthCheckInterval: 100 * time.Millisecond,
+		InitialBackoff:      10 * time.Millisecond,
+		MaxBackoff:          50 * time.Millisecond,
+	})
+	require.NoError(t, err, "degraded server should not fail startup")
+	defer servers.Close()
+
+	provider, err := ToolProvider(servers, []config.MCPToolsReference{{MCPServer: "home"}})
+	require.NoError(t, err)
+
+	tools, err := provider.Tools(ctx)
+	require.NoError(t, err, "tools of degraded servers should be excluded")
+	require.Empty(t, tools, "tools of degraded server")
+
+	listener, err = net.Listen("tcp", addr)
+	require.NoError(t, err)
+
+	srv := &http.Server{Handler: mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return newFakeMCPServer() }, nil)}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	require.Eventually(t, func() bool {
+		tools, err = provider.Tools(ctx)
+		return err == nil && len(tools) == 1
+	}, 5*time.Second, 20*time.Millisecond, "server should recover")
+}
@@ -2,6 +2,9 @@ package mcp
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 
 	"github.com/mgoltzsche/ai-assistant-vui/internal/tools"
 	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
@@ -64,6 +67,12 @@ func (p toolProviderList) Tools(ctx context.Context) ([]tools.Tool, error) {
 	for _, provider := range p {
 		t, err := provider.Tools(ctx)
 		if err != nil {
+			if errors.Is(err, ErrDegraded) {
+				// Offer the tools of the other servers while the degraded one is restarted.
+				slog.Warn(fmt.Sprintf("excluding tools: %s", err))
+				continue
+			}
+
 			return nil, err
 		}
 
@@ -0,0 +1,118 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+
+	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// TransportType specifies how to connect to a remote MCP server.
+type TransportType string
+
+const (
+	// TransportStreamable connects to an MCP server using the Streamable HTTP transport.
+	TransportStreamable TransportType = "streamable"
+	// TransportSSE connects to an MCP server using the legacy HTTP+SSE transport.
+	TransportSSE TransportType = "sse"
+)
+
+// validateServer verifies the MCP server configuration.
+func validateServer(s config.MCPServer) error {
+	switch {
+	case s.Command != "" && s.URL != "":
+		return errors.New("either command or url must be specified, not both")
+	case s.Command != "":
+		return nil
+	case s.URL == "":
+		return errors.New("neither command nor url specified")
+	case len(s.Env) > 0 || len(s.EnvFrom) > 0 || len(s.SecretFiles) > 0 || s.WorkingDir != "":
+		return errors.New("env, envFrom, secretFiles and workingDir can only be specified for commands")
+	}
+
+	switch TransportType(s.Transport) {
+	case "", TransportStreamable, TransportSSE:
+		return nil
+	default:
+		return fmt.Errorf("unsupported transport %q", s.Transport)
+	}
+}
+
+// newTransport returns the transport to connect to the given MCP server with.
+// Servers with a command are launched as child process, communicating over stdin/stdout.
+func newTransport(s config.MCPServer) (mcp.Transport, error) {
+	err := validateServer(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Command != "" {
+		return commandTransport(s)
+	}
+
+	headers := make(http.Header, len(s.Headers)+1)
+	for k, v := range s.Headers {
+		v, err := expandEnvRefs(v)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", k, err)
+		}
+
+		headers.Set(k, v)
+	}
+
+	if s.BearerToken != "" {
+		token, err := expandEnvRefs(s.BearerToken)
+		if err != nil {
+			return nil, fmt.Errorf("bearer token: %w", err)
+		}
+
+		headers.Set("Authorization", "Bearer "+token)
+	}
+
+	// No client timeout since the connection is kept open to receive server events.
+	httpClient := &http.Client{
+		Transport: &headerTransport{
+			headers:  headers,
+			delegate: http.DefaultTransport,
+		},
+	}
+
+	if TransportType(s.Transport) == TransportSSE {
+		return &mcp.SSEClientTransport{Endpoint: s.URL, HTTPClient: httpClient}, nil
+	}
+
+	return &mcp.StreamableClientTransport{Endpoint: s.URL, HTTPClient: httpClient}, nil
+}
+
+// commandTransport launches the MCP server as child process within the configured environment.
+func commandTransport(s config.MCPServer) (mcp.Transport, error) {
+	env, err := processEnv(s)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(s.Command, s.Args...)
+	cmd.Dir = s.WorkingDir
+	cmd.Env = env
+
+	return &mcp.CommandTransport{Command: cmd}, nil
+}
+
+// headerTransport adds the configured headers to every request.
+type headerTransport struct {
+	headers  http.Header
+	delegate http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	for k, v := range t.headers {
+		req.Header[k] = v
+	}
+
+	return t.delegate.RoundTrip(req)
+}
@@ -0,0 +1,88 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/require"
+)
+
+type echoInput struct {
+	Text string `json:"text"`
+}
+
+func newFakeMCPServer() *mcp.Server {
+	server := mcp.NewServer(&mcp.Implementation{Name: "fake", Version: "v0.0.1"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "echo", Description: "Echoes the text."},
+		func(ctx context.Context, req *mcp.CallToolRequest, in echoInput) (*mcp.CallToolResult, any, error) {
+			return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: in.Text}}}, nil, nil
+		})
+
+	return server
+}
+
+func TestRemoteMCPServer(t *testing.T) {
+	for _, c := range []struct {
+		transport string
+		handler   http.Handler
+	}{
+		{
+			transport: "streamable",
+			handler:   mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return newFakeMCPServer() }, nil),
+		},
+		{
+			transport: "sse",
+			handler:   mcp.NewSSEHandler(func(*http.Request) *mcp.Server { return newFakeMCPServer() }, nil),
+		},
+	} {
+		t.Run(c.transport, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.Header.Get("Authorization") != "Bearer fake-token" || req.Header.Get("X-Home") != "living-room" {
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+					return
+				}
+
+				c.handler.ServeHTTP(w, req)
+			}))
+			defer srv.Close()
+
+			ctx := context.Background()
+			servers, err := NewServers(ctx, map[string]config.MCPServer{
+				"home": {
+					URL:         srv.URL,
+					Transport:   c.transport,
+					Headers:     map[string]string{"X-Home": "living-room"},
+					BearerToken: "fake-token",
+				},
+			}, SupervisionPolicy{})
+			require.NoError(t, err)
+			defer servers.Close()
+
+			provider, err := ToolProvider(servers, []config.MCPToolsReference{{MCPServer: "home"}})
+			require.NoError(t, err)
+
+			tools, err := provider.Tools(ctx)
+			require.NoError(t, err)
+			require.Len(t, tools, 1, "tools")
+
+			result, err := tools[0].Call(ctx, `{"text":"hello"}`)
+			require.NoError(t, err)
+			require.Equal(t, "hello", result)
+		})
+	}
+}
+
+func TestNewTransportInvalid(t *testing.T) {
+	for _, s := range []config.MCPServer{
+		{},
+		{Command: "fake", URL: "http://localhost"},
+		{URL: "http://localhost", Transport: "websocket"},
+	} {
+		_, err := newTransport(s)
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,36 @@
+package tools
+
+import (
+	"context"
+)
+
+// Attachment is binary content returned by a tool, e.g. an image or audio clip.
+type Attachment struct {
+	MIMEType string
+	Data     []byte
+}
+
+// Result is the result of a tool call that may contain binary content in addition to text.
+type Result struct {
+	// Text is passed to the LLM as tool result.
+	Text string
+	// Attachments are images that are passed to the LLM and audio clips that are played to the user.
+	Attachments []Attachment
+}
+
+// RichTool is a tool that can return binary content along with its textual result.
+type RichTool interface {
+	Tool
+	CallRich(ctx context.Context, params string) (Result, error)
+}
+
+// Call calls the given tool, returning its attachments if it supports them.
+func Call(ctx context.Context, tool Tool, params string) (Result, error) {
+	if t, ok := tool.(RichTool); ok {
+		return t.CallRich(ctx, params)
+	}
+
+	text, err := tool.Call(ctx, params)
+
+	return Result{Text: text}, err
+}
@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ArgumentsError is returned when a tool is called with arguments that don't match its parameter schema.
+type ArgumentsError struct {
+	Tool    string
+	Message string
+}
+
+func (e *ArgumentsError) Error() string {
+	return fmt.Sprintf("invalid arguments provided to tool %q: %s", e.Tool, e.Message)
+}
+
+// ToolResult returns the error as structured tool response to let the LLM correct the call.
+func (e *ArgumentsError) ToolResult() string {
+	b, _ := json.Marshal(map[string]string{
+		"error":   "invalid_arguments",
+		"tool":    e.Tool,
+		"message": e.Message,
+		"hint":    "Call the tool again with arguments that match its parameter schema.",
+	})
+
+	return string(b)
+}
+
+// ValidateArguments validates the JSON arguments of a tool call against the tool's parameter schema.
+// The parameters may be specified as jsonschema.Definition or as map (as returned by MCP servers).
+func ValidateArguments(def llms.FunctionDefinition, args string) error {
+	if len(args) == 0 {
+		return &ArgumentsError{Tool: def.Name, Message: "function called with empty arguments"}
+	}
+
+	if def.Parameters == nil {
+		return nil
+	}
+
+	s, err := toSchema(def.Parameters)
+	if err != nil {
+		return fmt.Errorf("tool %q: %w", def.Name, err)
+	}
+
+	if s.Type != "" && s.Type != "object" {
+		return nil // non-object arguments are passed through as is
+	}
+
+	if len(s.Properties) > 0 && s.AdditionalProperties == nil {
+		// Reject properties the LLM came up with.
+		s.AdditionalProperties = &jsonschema.Schema{Not: &jsonschema.Schema{}}
+	}
+
+	resolved, err := s.Resolve(nil)
+	if err != nil {
+		return fmt.Errorf("tool %q: resolve parameter schema: %w", def.Name, err)
+	}
+
+	var argObj map[string]any
+
+	err = json.Unmarshal([]byte(args), &argObj)
+	if err != nil {
+		return &ArgumentsError{Tool: def.Name, Message: fmt.Sprintf("arguments must be a JSON object: %s", err)}
+	}
+
+	err = resolved.Validate(argObj)
+	if err != nil {
+		return &ArgumentsError{Tool: def.Name, Message: err.Error()}
+	}
+
+	return nil
+}
+
+func toSchema(params any) (*jsonschema.Schema, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("marshal parameter schema: %w", err)
+	}
+
+	s := &jsonschema.Schema{}
+
+	err = json.Unmarshal(b, s)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal parameter schema: %w", err)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/jsonschema"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestValidateArguments(t *testing.T) {
+	definitionSchema := jsonschema.Definition{
+		Type: "object",
+		Properties: map[string]jsonschema.Definition{
+			"volume": {
+				Type:        "integer",
+				Description: "The volume to set.",
+			},
+		},
+		Required: []string{"volume"},
+	}
+	mcpSchema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"volume": map[string]any{
+				"type":    "integer",
+				"maximum": 100,
+			},
+		},
+		"required": []any{"volume"},
+	}
+
+	for _, tc := range []struct {
+		name  string
+		args  string
+		valid bool
+	}{
+		{
+			name:  "valid",
+			args:  `{"volume": 30}`,
+			valid: true,
+		},
+		{
+			name: "empty",
+			args: ``,
+		},
+		{
+			name: "no JSON object",
+			args: `30`,
+		},
+		{
+			name: "wrong type",
+			args: `{"volume": "thirty"}`,
+		},
+		{
+			name: "missing required property",
+			args: `{}`,
+		},
+		{
+			name: "unknown property",
+			args: `{"volume": 30, "invented": true}`,
+		},
+	} {
+		for schemaName, params := range map[string]any{"definition": definitionSchema, "map": mcpSchema} {
+			t.Run(schemaName+" "+tc.name, func(t *testing.T) {
+				err := ValidateArguments(llms.FunctionDefinition{
+					Name:       "set_volume",
+					Parameters: params,
+				}, tc.args)
+
+				if tc.valid {
+					require.NoError(t, err)
+				} else {
+					var argsErr *ArgumentsError
+					require.True(t, errors.As(err, &argsErr), "should return ArgumentsError but returned %#v", err)
+				}
+			})
+		}
+	}
+
+	t.Run("map value out of range", func(t *testing.T) {
+		err := ValidateArguments(llms.FunctionDefinition{
+			Name:       "set_volume",
+			Parameters: mcpSchema,
+		}, `{"volume": 101}`)
+
+		var argsErr *ArgumentsError
+		require.True(t, errors.As(err, &argsErr), "should return ArgumentsError but returned %#v", err)
+		require.Contains(t, argsErr.Message, "maximum")
+	})
+}
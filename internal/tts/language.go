@@ -0,0 +1,519 @@
+package tts
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// language provides the words and number formats of a language to spell out text.
+type language struct {
+	number  func(n int64) string
+	ordinal func(n int64) string
+	year    func(n int64) string
+	time    func(hour, minute int64) string
+	date    func(year, month, day int64) string
+	// units maps unit symbols to their singular (including the number one) and plural names.
+	units map[string][2]string
+	words languageWords
+	// dateRegex matches dates in the language's format.
+	dateRegex *regexp.Regexp
+	// dateOrder holds the dateRegex submatch indexes of day, month and year.
+	dateOrder    [3]int
+	numberRegex  *regexp.Regexp
+	ordinalRegex *regexp.Regexp
+	unitRegex    *regexp.Regexp
+	// thousandsSeparator and decimalSeparator are the number format characters.
+	thousandsSeparator, decimalSeparator string
+}
+
+type languageWords struct {
+	minus, point, dot, slash, dash, underscore string
+}
+
+var languages = map[string]*language{
+	"en": newLanguage(language{
+		number:  englishNumber,
+		ordinal: englishOrdinal,
+		year:    englishYear,
+		time:    englishTime,
+		date: func(year, month, day int64) string {
+			return englishMonths[month-1] + " " + englishOrdinal(day) + ", " + englishYear(year)
+		},
+		units: map[string][2]string{
+			"km/h": {"one kilometer per hour", "kilometers per hour"},
+			"mph":  {"one mile per hour", "miles per hour"},
+			"km":   {"one kilometer", "kilometers"},
+			"m":    {"one meter", "meters"},
+			"cm":   {"one centimeter", "centimeters"},
+			"mm":   {"one millimeter", "millimeters"},
+			"kg":   {"one kilogram", "kilograms"},
+			"g":    {"one gram", "grams"},
+			"mg":   {"one milligram", "milligrams"},
+			"l":    {"one liter", "liters"},
+			"ml":   {"one milliliter", "milliliters"},
+			"kWh":  {"one kilowatt hour", "kilowatt hours"},
+			"kW":   {"one kilowatt", "kilowatts"},
+			"W":    {"one watt", "watts"},
+			"TB":   {"one terabyte", "terabytes"},
+			"GB":   {"one gigabyte", "gigabytes"},
+			"MB":   {"one megabyte", "megabytes"},
+			"°C":   {"one degree Celsius", "degrees Celsius"},
+			"°F":   {"one degree Fahrenheit", "degrees Fahrenheit"},
+			"°":    {"one degree", "degrees"},
+			"%":    {"one percent", "percent"},
+			"h":    {"one hour", "hours"},
+			"min":  {"one minute", "minutes"},
+			"ms":   {"one millisecond", "milliseconds"},
+			"$":    {"one dollar", "dollars"},
+			"€":    {"one euro", "euros"},
+			"£":    {"one pound", "pounds"},
+		},
+		words: languageWords{
+			minus: "minus", point: "point", dot: "dot", slash: "slash", dash: "dash", underscore: "underscore",
+		},
+		numberRegex:        regexp.MustCompile(`\b(?:\d{1,3}(?:,\d{3})+(?:\.\d+)?|\d+(?:\.\d+)?)\b`),
+		dateRegex:          regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})/(\d{4})\b`),
+		dateOrder:          [3]int{2, 1, 3}, // month/day/year
+		ordinalRegex:       regexp.MustCompile(`\b(\d+)(?:st|nd|rd|th)\b`),
+		thousandsSeparator: ",",
+		decimalSeparator:   ".",
+	}),
+	"de": newLanguage(language{
+		number:  germanNumber,
+		ordinal: germanOrdinal,
+		year:    germanYear,
+		time:    germanTime,
+		date: func(year, month, day int64) string {
+			return germanOrdinal(day) + " " + germanMonths[month-1] + " " + germanYear(year)
+		},
+		units: map[string][2]string{
+			"km/h": {"ein Kilometer pro Stunde", "Kilometer pro Stunde"},
+			"mph":  {"eine Meile pro Stunde", "Meilen pro Stunde"},
+			"km":   {"ein Kilometer", "Kilometer"},
+			"m":    {"ein Meter", "Meter"},
+			"cm":   {"ein Zentimeter", "Zentimeter"},
+			"mm":   {"ein Millimeter", "Millimeter"},
+			"kg":   {"ein Kilogramm", "Kilogramm"},
+			"g":    {"ein Gramm", "Gramm"},
+			"mg":   {"ein Milligramm", "Milligramm"},
+			"l":    {"ein Liter", "Liter"},
+			"ml":   {"ein Milliliter", "Milliliter"},
+			"kWh":  {"eine Kilowattstunde", "Kilowattstunden"},
+			"kW":   {"ein Kilowatt", "Kilowatt"},
+			"W":    {"ein Watt", "Watt"},
+			"TB":   {"ein Terabyte", "Terabyte"},
+			"GB":   {"ein Gigabyte", "Gigabyte"},
+			"MB":   {"ein Megabyte", "Megabyte"},
+			"°C":   {"ein Grad Celsius", "Grad Celsius"},
+			"°F":   {"ein Grad Fahrenheit", "Grad Fahrenheit"},
+			"°":    {"ein Grad", "Grad"},
+			"%":    {"ein Prozent", "Prozent"},
+			"h":    {"eine Stunde", "Stunden"},
+			"min":  {"eine Minute", "Minuten"},
+			"ms":   {"eine Millisekunde", "Millisekunden"},
+			"$":    {"ein Dollar", "Dollar"},
+			"€":    {"ein Euro", "Euro"},
+			"£":    {"ein Pfund", "Pfund"},
+		},
+		words: languageWords{
+			minus: "minus", point: "Komma", dot: "Punkt", slash: "Schrägstrich", dash: "Bindestrich", underscore: "Unterstrich",
+		},
+		numberRegex:        regexp.MustCompile(`\b(?:\d{1,3}(?:\.\d{3})+(?:,\d+)?|\d+(?:,\d+)?)\b`),
+		dateRegex:          regexp.MustCompile(`\b(\d{1,2})\.(\d{1,2})\.(\d{4})\b`),
+		dateOrder:          [3]int{1, 2, 3}, // day.month.year
+		thousandsSeparator: ".",
+		decimalSeparator:   ",",
+	}),
+}
+
+var (
+	isoDateRegex   = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+	timeRegex      = regexp.MustCompile(`\b(\d{1,2}):(\d{2})\b`)
+	currencyRegex  = regexp.MustCompile(`([$€£])\s?(\d+(?:[.,]\d+)*)`)
+	unitValueRegex = `(\d+(?:[.,]\d+)*)\s?(`
+)
+
+// maxSpokenNumber is the limit up to which numbers are spelled out as a whole.
+// Larger numbers, e.g. phone numbers, are spelled digit by digit.
+const maxSpokenNumber = 999_999_999_999
+
+func newLanguage(l language) *language {
+	symbols := make([]string, 0, len(l.units))
+	for symbol := range l.units {
+		symbols = append(symbols, symbol)
+	}
+
+	// Match longer symbols first, e.g. km/h before km.
+	sort.Slice(symbols, func(i, j int) bool {
+		if len(symbols[i]) != len(symbols[j]) {
+			return len(symbols[i]) > len(symbols[j])
+		}
+
+		return symbols[i] < symbols[j]
+	})
+
+	for i, symbol := range symbols {
+		symbols[i] = regexp.QuoteMeta(symbol)
+	}
+
+	l.unitRegex = regexp.MustCompile(unitValueRegex + strings.Join(symbols, "|") + ")")
+
+	return &l
+}
+
+// expandDates spells out ISO dates and dates in the language's format.
+func (l *language) expandDates(text string) string {
+	text = isoDateRegex.ReplaceAllStringFunc(text, func(s string) string {
+		m := isoDateRegex.FindStringSubmatch(s)
+		return l.spellDate(s, m[1], m[2], m[3])
+	})
+
+	return l.dateRegex.ReplaceAllStringFunc(text, func(s string) string {
+		m := l.dateRegex.FindStringSubmatch(s)
+		return l.spellDate(s, m[l.dateOrder[2]], m[l.dateOrder[1]], m[l.dateOrder[0]])
+	})
+}
+
+func (l *language) spellDate(orig, year, month, day string) string {
+	y, _ := strconv.ParseInt(year, 10, 64)
+	m, _ := strconv.ParseInt(month, 10, 64)
+	d, _ := strconv.ParseInt(day, 10, 64)
+
+	if m < 1 || m > 12 || d < 1 || d > 31 {
+		return orig
+	}
+
+	return l.date(y, m, d)
+}
+
+// expandTimes spells out times such as 14:30.
+func (l *language) expandTimes(text string) string {
+	return timeRegex.ReplaceAllStringFunc(text, func(s string) string {
+		m := timeRegex.FindStringSubmatch(s)
+		hour, _ := strconv.ParseInt(m[1], 10, 64)
+		minute, _ := strconv.ParseInt(m[2], 10, 64)
+
+		if hour > 24 || minute > 59 {
+			return s
+		}
+
+		return l.time(hour, minute)
+	})
+}
+
+// expandUnits spells out units and currencies that follow a number, e.g. 5km/h.
+// The number itself is expanded afterwards unless it is one.
+func (l *language) expandUnits(text string) string {
+	text = currencyRegex.ReplaceAllString(text, "$2 $1")
+
+	matches := l.unitRegex.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+
+	pos := 0
+
+	for _, m := range matches {
+		if next, _ := utf8.DecodeRuneInString(text[m[1]:]); m[1] < len(text) && (unicode.IsLetter(next) || unicode.IsDigit(next)) {
+			continue // not a unit but the prefix of a word
+		}
+
+		if m[0] > 0 {
+			if prev, _ := utf8.DecodeLastRuneInString(text[:m[0]]); unicode.IsLetter(prev) {
+				continue // a number within a word, e.g. mp3
+			}
+		}
+
+		value := text[m[2]:m[3]]
+		names := l.units[text[m[4]:m[5]]]
+
+		b.WriteString(text[pos:m[0]])
+
+		if value == "1" {
+			b.WriteString(names[0])
+		} else {
+			b.WriteString(value)
+			b.WriteString(" ")
+			b.WriteString(names[1])
+		}
+
+		pos = m[1]
+	}
+
+	b.WriteString(text[pos:])
+
+	return b.String()
+}
+
+// expandOrdinals spells out ordinal numbers such as 1st.
+func (l *language) expandOrdinals(text string) string {
+	if l.ordinalRegex == nil {
+		return text
+	}
+
+	return l.ordinalRegex.ReplaceAllStringFunc(text, func(s string) string {
+		n, err := strconv.ParseInt(l.ordinalRegex.FindStringSubmatch(s)[1], 10, 64)
+		if err != nil || n > maxSpokenNumber {
+			return s
+		}
+
+		return l.ordinal(n)
+	})
+}
+
+// expandNumbers spells out numbers.
+func (l *language) expandNumbers(text string) string {
+	return l.numberRegex.ReplaceAllStringFunc(text, func(s string) string {
+		integer, decimals, _ := strings.Cut(strings.ReplaceAll(s, l.thousandsSeparator, ""), l.decimalSeparator)
+
+		n, err := strconv.ParseInt(integer, 10, 64)
+		if err != nil || n > maxSpokenNumber || (len(integer) > 1 && integer[0] == '0') {
+			return l.spellDigits(integer)
+		}
+
+		spoken := l.number(n)
+
+		if decimals != "" {
+			spoken += " " + l.words.point + " " + l.spellDigits(decimals)
+		}
+
+		return spoken
+	})
+}
+
+func (l *language) spellDigits(digits string) string {
+	words := make([]string, 0, len(digits))
+
+	for _, d := range digits {
+		words = append(words, l.number(int64(d-'0')))
+	}
+
+	return strings.Join(words, " ")
+}
+
+// spellURL returns the spoken form of a URL without scheme.
+func (l *language) spellURL(url string) string {
+	return strings.NewReplacer(
+		".", " "+l.words.dot+" ",
+		"/", " "+l.words.slash+" ",
+		"-", " "+l.words.dash+" ",
+		"_", " "+l.words.underscore+" ",
+	).Replace(url)
+}
+
+var (
+	englishOnes = []string{
+		"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen",
+	}
+	englishTens     = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+	englishOrdinals = map[string]string{
+		"one": "first", "two": "second", "three": "third", "five": "fifth", "eight": "eighth", "nine": "ninth", "twelve": "twelfth",
+	}
+	englishMonths = []string{
+		"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December",
+	}
+	englishScales = []struct {
+		value int64
+		name  string
+	}{
+		{1_000_000_000, "billion"},
+		{1_000_000, "million"},
+		{1_000, "thousand"},
+		{100, "hundred"},
+	}
+)
+
+func englishNumber(n int64) string {
+	switch {
+	case n < 0:
+		return "minus " + englishNumber(-n)
+	case n < 20:
+		return englishOnes[n]
+	case n < 100:
+		s := englishTens[n/10]
+		if n%10 != 0 {
+			s += "-" + englishOnes[n%10]
+		}
+
+		return s
+	}
+
+	for _, scale := range englishScales {
+		if n >= scale.value {
+			s := englishNumber(n/scale.value) + " " + scale.name
+			if rest := n % scale.value; rest != 0 {
+				s += " " + englishNumber(rest)
+			}
+
+			return s
+		}
+	}
+
+	return ""
+}
+
+func englishOrdinal(n int64) string {
+	s := englishNumber(n)
+	i := strings.LastIndexAny(s, " -") + 1
+
+	if o, ok := englishOrdinals[s[i:]]; ok {
+		return s[:i] + o
+	}
+
+	if strings.HasSuffix(s, "y") {
+		return strings.TrimSuffix(s, "y") + "ieth"
+	}
+
+	return s + "th"
+}
+
+func englishYear(year int64) string {
+	if year < 1100 || year > 9999 || (year >= 2000 && year < 2010) {
+		return englishNumber(year)
+	}
+
+	century, rest := year/100, year%100
+
+	switch {
+	case rest == 0:
+		return englishNumber(century) + " hundred"
+	case rest < 10:
+		return englishNumber(century) + " oh " + englishNumber(rest)
+	default:
+		return englishNumber(century) + " " + englishNumber(rest)
+	}
+}
+
+func englishTime(hour, minute int64) string {
+	switch {
+	case minute == 0:
+		return englishNumber(hour) + " o'clock"
+	case minute < 10:
+		return englishNumber(hour) + " oh " + englishNumber(minute)
+	default:
+		return englishNumber(hour) + " " + englishNumber(minute)
+	}
+}
+
+var (
+	germanOnes = []string{
+		"null", "eins", "zwei", "drei", "vier", "fünf", "sechs", "sieben", "acht", "neun",
+		"zehn", "elf", "zwölf", "dreizehn", "vierzehn", "fünfzehn", "sechzehn", "siebzehn", "achtzehn", "neunzehn",
+	}
+	germanTens     = []string{"", "", "zwanzig", "dreißig", "vierzig", "fünfzig", "sechzig", "siebzig", "achtzig", "neunzig"}
+	germanOrdinals = map[int64]string{1: "erster", 3: "dritter", 7: "siebter", 8: "achter"}
+	germanMonths   = []string{
+		"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember",
+	}
+	germanScales = []struct {
+		value            int64
+		singular, plural string
+	}{
+		{1_000_000_000, "Milliarde", "Milliarden"},
+		{1_000_000, "Million", "Millionen"},
+	}
+)
+
+func germanNumber(n int64) string {
+	switch {
+	case n < 0:
+		return "minus " + germanNumber(-n)
+	case n == 1:
+		return "eins"
+	case n < 1_000_000:
+		return germanCompound(n)
+	}
+
+	for _, scale := range germanScales {
+		if n >= scale.value {
+			s := "eine " + scale.singular
+			if count := n / scale.value; count > 1 {
+				s = germanNumber(count) + " " + scale.plural
+			}
+
+			if rest := n % scale.value; rest != 0 {
+				s += " " + germanNumber(rest)
+			}
+
+			return s
+		}
+	}
+
+	return ""
+}
+
+// germanCompound spells out numbers below one million as used within compound words, e.g. "ein" instead of "eins".
+func germanCompound(n int64) string {
+	switch {
+	case n == 1:
+		return "ein"
+	case n < 20:
+		return germanOnes[n]
+	case n < 100:
+		s := germanTens[n/10]
+		if n%10 != 0 {
+			s = germanCompound(n%10) + "und" + s
+		}
+
+		return s
+	case n < 1000:
+		s := germanCompound(n/100) + "hundert"
+		if rest := n % 100; rest != 0 {
+			s += germanNumber(rest)
+		}
+
+		return s
+	default:
+		s := germanCompound(n/1000) + "tausend"
+		if rest := n % 1000; rest != 0 {
+			s += germanNumber(rest)
+		}
+
+		return s
+	}
+}
+
+func germanOrdinal(n int64) string {
+	if o, ok := germanOrdinals[n]; ok {
+		return o
+	}
+
+	if n < 20 {
+		return germanNumber(n) + "ter"
+	}
+
+	return germanNumber(n) + "ster"
+}
+
+func germanYear(year int64) string {
+	if year < 1100 || year > 1999 {
+		return germanNumber(year)
+	}
+
+	s := germanCompound(year/100) + "hundert"
+	if rest := year % 100; rest != 0 {
+		s += germanNumber(rest)
+	}
+
+	return s
+}
+
+func germanTime(hour, minute int64) string {
+	h := germanNumber(hour)
+	if hour == 1 {
+		h = "ein"
+	}
+
+	if minute == 0 {
+		return h + " Uhr"
+	}
+
+	return h + " Uhr " + germanNumber(minute)
+}
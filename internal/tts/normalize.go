@@ -0,0 +1,146 @@
+package tts
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// URLMode specifies how URLs are spoken.
+type URLMode string
+
+const (
+	// URLModeShorten speaks the host name of a URL only.
+	URLModeShorten URLMode = "shorten"
+	// URLModeSpell spells out the whole URL.
+	URLModeSpell URLMode = "spell"
+	// URLModeRemove drops URLs.
+	URLModeRemove URLMode = "remove"
+)
+
+// Normalizer converts text into a form that is pronounced correctly by a TTS model.
+// It strips Markdown and emojis, shortens URLs and expands numbers, dates, times and units into words.
+type Normalizer struct {
+	lang    *language
+	urlMode URLMode
+}
+
+// NewNormalizer creates a normalizer for the given language (en or de).
+func NewNormalizer(lang string, urlMode URLMode) (*Normalizer, error) {
+	code := strings.ToLower(lang)
+	if i := strings.IndexAny(code, "-_"); i > 0 {
+		code = code[:i] // e.g. en-US
+	}
+
+	if code == "" {
+		code = "en"
+	}
+
+	l, ok := languages[code]
+	if !ok {
+		return nil, fmt.Errorf("unsupported speech normalization language %q", lang)
+	}
+
+	switch urlMode {
+	case "":
+		urlMode = URLModeShorten
+	case URLModeShorten, URLModeSpell, URLModeRemove:
+	default:
+		return nil, fmt.Errorf("unsupported URL mode %q", urlMode)
+	}
+
+	return &Normalizer{lang: l, urlMode: urlMode}, nil
+}
+
+var (
+	whitespaceRegex = regexp.MustCompile(`[ \t]+`)
+	minusRegex      = regexp.MustCompile(`(^|\s)-(\d)`)
+)
+
+// Normalize returns the given text in a form that is suitable for speech synthesis.
+func (n *Normalizer) Normalize(text string) string {
+	text = stripMarkdown(text)
+	text = n.replaceURLs(text)
+	text = strings.ReplaceAll(text, "_", " ")
+	text = removeEmojis(text)
+	text = n.lang.expandDates(text)
+	text = n.lang.expandTimes(text)
+	text = n.lang.expandUnits(text)
+	text = n.lang.expandOrdinals(text)
+	text = minusRegex.ReplaceAllString(text, "${1}"+n.lang.words.minus+" ${2}")
+	text = n.lang.expandNumbers(text)
+	text = whitespaceRegex.ReplaceAllString(text, " ")
+
+	return text
+}
+
+var markdownReplacements = []struct {
+	regex       *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile("(?m)^\\s*```.*$"), ""},
+	{regexp.MustCompile(`(?m)^\s*(?:[-*_]\s*){3,}$`), ""},
+	{regexp.MustCompile(`(?m)^\s*\|?(?:\s*:?-+:?\s*\|)+\s*:?-*:?\s*$`), ""},
+	{regexp.MustCompile(`(?m)^\s{0,3}#{1,6}\s+`), ""},
+	{regexp.MustCompile(`(?m)^\s*>\s?`), ""},
+	{regexp.MustCompile(`(?m)^\s*(?:[-*+]|\d+[.)])\s+`), ""},
+	{regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`), "$1"},
+	{regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`), "$1"},
+	{regexp.MustCompile(`<((?:https?://|www\.)[^>\s]+)>`), "$1"},
+	{regexp.MustCompile(`\*\*(.+?)\*\*`), "$1"},
+	{regexp.MustCompile(`__(.+?)__`), "$1"},
+	{regexp.MustCompile(`~~(.+?)~~`), "$1"},
+	{regexp.MustCompile(`\b_([^_\n]+)_\b`), "$1"},
+	{regexp.MustCompile("[*`]+"), ""},
+	{regexp.MustCompile(`\s*\|\s*`), ", "},
+}
+
+// stripMarkdown removes Markdown syntax, keeping the text only.
+func stripMarkdown(text string) string {
+	for _, r := range markdownReplacements {
+		text = r.regex.ReplaceAllString(text, r.replacement)
+	}
+
+	return strings.Trim(text, ", ")
+}
+
+var urlRegex = regexp.MustCompile(`(?:https?://|www\.)[^\s<>()\[\]]+`)
+
+// replaceURLs replaces URLs with their spoken form.
+func (n *Normalizer) replaceURLs(text string) string {
+	return urlRegex.ReplaceAllStringFunc(text, func(url string) string {
+		trimmed := strings.TrimRight(url, ".,;:!?'\"")
+		suffix := url[len(trimmed):]
+		url = trimmed[strings.Index(trimmed, "//")+1:]
+		url = strings.TrimPrefix(strings.TrimPrefix(url, "/"), "www.")
+
+		switch n.urlMode {
+		case URLModeRemove:
+			return suffix
+		case URLModeSpell:
+			url = strings.TrimRight(url, "/")
+		default:
+			if i := strings.IndexAny(url, "/?#:"); i >= 0 {
+				url = url[:i]
+			}
+		}
+
+		return n.lang.spellURL(url) + suffix
+	})
+}
+
+// removeEmojis removes emojis and pictographs that TTS models would read out or mispronounce.
+func removeEmojis(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 0x1F000 && r <= 0x1FAFF, // emoticons, pictographs, flags
+			r >= 0x2600 && r <= 0x27BF,   // miscellaneous symbols and dingbats
+			r >= 0x2B00 && r <= 0x2BFF,   // arrows and stars
+			r >= 0xE0020 && r <= 0xE007F, // tags
+			r == 0xFE0F, r == 0x200D:     // variation selector and zero width joiner
+			return -1
+		default:
+			return r
+		}
+	}, text)
+}
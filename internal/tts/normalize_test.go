@@ -0,0 +1,190 @@
+package tts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		lang     string
+		urlMode  URLMode
+		input    string
+		expected string
+	}{
+		{
+			name:     "plain text",
+			input:    "How can I help you today?",
+			expected: "How can I help you today?",
+		},
+		{
+			name:     "markdown emphasis",
+			input:    "This is **very** _important_ and `code`.",
+			expected: "This is very important and code.",
+		},
+		{
+			name:     "markdown heading",
+			input:    "## Weather\n",
+			expected: "Weather\n",
+		},
+		{
+			name:     "markdown list item",
+			input:    "- Buy **milk**\n",
+			expected: "Buy milk\n",
+		},
+		{
+			name:     "markdown code fence",
+			input:    "```go",
+			expected: "",
+		},
+		{
+			name:     "markdown link",
+			input:    "See [the docs](https://example.org/docs).",
+			expected: "See the docs.",
+		},
+		{
+			name:     "snake case",
+			input:    "Call set_volume_level now.",
+			expected: "Call set volume level now.",
+		},
+		{
+			name:     "shortened URL",
+			input:    "Visit https://www.example.org/some/path?q=1.",
+			expected: "Visit example dot org.",
+		},
+		{
+			name:     "spelled URL",
+			urlMode:  URLModeSpell,
+			input:    "Visit https://example.org/some-path/.",
+			expected: "Visit example dot org slash some dash path.",
+		},
+		{
+			name:     "removed URL",
+			urlMode:  URLModeRemove,
+			input:    "Visit https://example.org/some/path.",
+			expected: "Visit .",
+		},
+		{
+			name:     "emojis",
+			input:    "Have a nice day 😀👍🏽!",
+			expected: "Have a nice day !",
+		},
+		{
+			name:     "numbers",
+			input:    "I counted 42 apples, 1,250 pears and 3.14 melons.",
+			expected: "I counted forty-two apples, one thousand two hundred fifty pears and three point one four melons.",
+		},
+		{
+			name:     "large number",
+			input:    "There are 7000000000 people.",
+			expected: "There are seven billion people.",
+		},
+		{
+			name:     "number with leading zero",
+			input:    "Agent 007",
+			expected: "Agent zero zero seven",
+		},
+		{
+			name:     "number within word",
+			input:    "Play the mp3 file using qwen3.",
+			expected: "Play the mp3 file using qwen3.",
+		},
+		{
+			name:     "negative number with unit",
+			input:    "It is -5°C outside.",
+			expected: "It is minus five degrees Celsius outside.",
+		},
+		{
+			name:     "units",
+			input:    "Drive 5km/h for 1 km, then 100 m at 20%.",
+			expected: "Drive five kilometers per hour for one kilometer, then one hundred meters at twenty percent.",
+		},
+		{
+			name:     "unit prefix of a word",
+			input:    "I have 5 mice and 3 minutes.",
+			expected: "I have five mice and three minutes.",
+		},
+		{
+			name:     "currency",
+			input:    "It costs $5 or 1€.",
+			expected: "It costs five dollars or one euro.",
+		},
+		{
+			name:     "ordinals",
+			input:    "The 1st, 2nd, 3rd, 21st and 40th place.",
+			expected: "The first, second, third, twenty-first and fortieth place.",
+		},
+		{
+			name:     "ISO date",
+			input:    "It was released on 2024-05-17.",
+			expected: "It was released on May seventeenth, twenty twenty-four.",
+		},
+		{
+			name:     "US date",
+			input:    "Born on 7/4/1999.",
+			expected: "Born on July fourth, nineteen ninety-nine.",
+		},
+		{
+			name:     "years",
+			input:    "From 2000-01-01 to 1900-12-31.",
+			expected: "From January first, two thousand to December thirty-first, nineteen hundred.",
+		},
+		{
+			name:     "times",
+			input:    "Meet me at 14:30, 9:05 or 10:00.",
+			expected: "Meet me at fourteen thirty, nine oh five or ten o'clock.",
+		},
+		{
+			name:     "german numbers",
+			lang:     "de",
+			input:    "Ich habe 21 Äpfel, 1.250 Birnen und 3,5 Melonen.",
+			expected: "Ich habe einundzwanzig Äpfel, eintausendzweihundertfünfzig Birnen und drei Komma fünf Melonen.",
+		},
+		{
+			name:     "german large numbers",
+			lang:     "de",
+			input:    "Es sind 1000001 oder 2000000 Leute.",
+			expected: "Es sind eine Million eins oder zwei Millionen Leute.",
+		},
+		{
+			name:     "german units",
+			lang:     "de-DE",
+			input:    "Fahre 1 h mit 30 km/h.",
+			expected: "Fahre eine Stunde mit dreißig Kilometer pro Stunde.",
+		},
+		{
+			name:     "german date and time",
+			lang:     "de",
+			input:    "Am 17.05.2024 um 1:15 oder 14:00.",
+			expected: "Am siebzehnter Mai zweitausendvierundzwanzig um ein Uhr fünfzehn oder vierzehn Uhr.",
+		},
+		{
+			name:     "german year",
+			lang:     "de",
+			input:    "Seit 01.03.1999.",
+			expected: "Seit erster März neunzehnhundertneunundneunzig.",
+		},
+		{
+			name:     "german URL",
+			lang:     "de",
+			input:    "Siehe www.example.de.",
+			expected: "Siehe example Punkt de.",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			testee, err := NewNormalizer(c.lang, c.urlMode)
+			require.NoError(t, err)
+
+			actual := testee.Normalize(c.input)
+
+			require.Equal(t, c.expected, actual)
+		})
+	}
+}
+
+func TestNewNormalizerUnsupportedLanguage(t *testing.T) {
+	_, err := NewNormalizer("xx", "")
+	require.Error(t, err)
+}
@@ -15,6 +15,9 @@ type GeneratedSpeech = model.AudioMessage
 
 type SpeechGenerator struct {
 	Service *Client
+	// Normalizer optionally converts the text into a form that is pronounced correctly.
+	// The original text is kept within the generated speech message.
+	Normalizer *Normalizer
 }
 
 func (g *SpeechGenerator) GenerateAudio(ctx context.Context, requests <-chan Request, conv *model.Conversation) <-chan GeneratedSpeech {
@@ -29,12 +32,27 @@ func (g *SpeechGenerator) GenerateAudio(ctx context.Context, requests <-chan Req
 				continue
 			}
 
-			msg := strings.TrimSpace(req.Text)
+			if len(req.Audio) > 0 {
+				// Play audio clips as they are.
+				ch <- GeneratedSpeech{
+					Message:  req,
+					WaveData: req.Audio,
+				}
+
+				continue
+			}
+
+			msg := req.Text
+			if g.Normalizer != nil {
+				msg = g.Normalizer.Normalize(msg)
+			}
+
+			msg = strings.TrimSpace(msg)
 			if msg == "" {
 				continue
 			}
 
-			body, err := g.Service.GenerateAudio(ctx, msg)
+			body, err := g.Service.GenerateAudio(ctx, msg, req.Voice)
 			if err != nil {
 				slog.Error(fmt.Sprintf("generate speech: %s", err))
 				continue
@@ -2,6 +2,7 @@ package tts
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -14,14 +15,20 @@ type Client struct {
 	Model  string
 	Client *http.Client
 	APIKey string
+	// Voice is the default voice, used when no voice is requested explicitly.
+	Voice string
 }
 
-func (c *Client) GenerateAudio(ctx context.Context, msg string) (io.ReadCloser, error) {
+func (c *Client) GenerateAudio(ctx context.Context, msg, voice string) (io.ReadCloser, error) {
 	params := map[string]interface{}{
 		"input": msg,
 		"model": c.Model,
 	}
 
+	if voice = cmp.Or(voice, c.Voice); voice != "" {
+		params["voice"] = voice
+	}
+
 	body, err := json.Marshal(params)
 	if err != nil {
 		return nil, fmt.Errorf("marshal speech generation params: %w", err)
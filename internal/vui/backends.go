@@ -0,0 +1,165 @@
+package vui
+
+import (
+	"cmp"
+	"fmt"
+	"sync"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/chat"
+	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
+)
+
+const defaultBackendName = "default"
+
+// ChatBackends holds the chat backends that are shared by all channels,
+// so that concurrency limits, load and health are tracked per server rather than per channel.
+type ChatBackends struct {
+	configured []*chat.Backend
+	derived    map[derivedBackendKey]*chat.Backend
+	httpClient chat.HTTPDoer
+	mutex      sync.Mutex
+}
+
+type derivedBackendKey struct {
+	ServerURL string
+	APIKey    string
+	Model     string
+}
+
+// NewChatBackends creates the configured chat backends.
+// Without explicitly configured backends, a default backend is derived from the top-level server settings.
+func NewChatBackends(cfg config.Configuration, httpClient chat.HTTPDoer) (*ChatBackends, error) {
+	backends := make([]*chat.Backend, len(cfg.Backends))
+
+	for i, b := range cfg.Backends {
+		if b.Name == "" {
+			return nil, fmt.Errorf("backends[%d]: no name specified", i)
+		}
+
+		for _, other := range backends[:i] {
+			if other.Name == b.Name {
+				return nil, fmt.Errorf("duplicate chat backend name %q", b.Name)
+			}
+		}
+
+		if b.ServerURL == "" || b.Model == "" {
+			return nil, fmt.Errorf("chat backend %s: serverURL and model must be specified", b.Name)
+		}
+
+		thinkingMode, err := chat.ParseThinkingMode(b.ThinkingMode)
+		if err != nil {
+			return nil, fmt.Errorf("chat backend %s: %w", b.Name, err)
+		}
+
+		backends[i] = &chat.Backend{
+			Name:           b.Name,
+			ServerURL:      b.ServerURL,
+			APIKey:         b.APIKey,
+			Model:          b.Model,
+			MaxTokens:      b.MaxTokens,
+			MaxConcurrency: b.MaxConcurrency,
+			ThinkingMode:   thinkingMode,
+			HTTPClient:     httpClient,
+		}
+	}
+
+	return &ChatBackends{
+		configured: backends,
+		derived:    map[derivedBackendKey]*chat.Backend{},
+		httpClient: httpClient,
+	}, nil
+}
+
+// forConfig returns the configured backends or, if none are configured, the backend derived from the top-level server settings.
+func (r *ChatBackends) forConfig(cfg config.Configuration) []*chat.Backend {
+	if len(r.configured) == 0 {
+		return []*chat.Backend{r.derivedBackend(defaultBackendName, cfg.ServerURL, cfg.APIKey, cfg.ChatModel)}
+	}
+
+	return r.configured
+}
+
+// derivedBackend returns the backend for the given server and model, creating it if it does not exist yet.
+// Channels and agents that use the same server and model share a backend.
+func (r *ChatBackends) derivedBackend(name, serverURL, apiKey, model string) *chat.Backend {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := derivedBackendKey{ServerURL: serverURL, APIKey: apiKey, Model: model}
+
+	b, ok := r.derived[key]
+	if !ok {
+		b = &chat.Backend{
+			Name:       name,
+			ServerURL:  serverURL,
+			APIKey:     apiKey,
+			Model:      model,
+			HTTPClient: r.httpClient,
+		}
+		r.derived[key] = b
+	}
+
+	return b
+}
+
+// selectChatBackends returns the backends referenced by the agent in order of preference.
+// All backends are returned when the agent does not reference any.
+func selectChatBackends(backends []*chat.Backend, agent config.AgentDefinition) ([]*chat.Backend, error) {
+	switch chat.RoutingStrategy(agent.Routing) {
+	case "", chat.RoutingFailover, chat.RoutingHealth:
+	default:
+		return nil, fmt.Errorf("unsupported chat backend routing strategy %q", agent.Routing)
+	}
+
+	if len(agent.UseBackends) == 0 {
+		return backends, nil
+	}
+
+	selected := make([]*chat.Backend, 0, len(agent.UseBackends))
+
+	for _, name := range agent.UseBackends {
+		found := false
+
+		for _, b := range backends {
+			if b.Name == name {
+				selected = append(selected, b)
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("chat backend %q is not defined", name)
+		}
+	}
+
+	return selected, nil
+}
+
+// agentChatBackends returns the backends of an agent.
+// A dedicated backend is created when the agent overrides the chat server or model.
+func agentChatBackends(cfg config.Configuration, agent config.Agent, backends *ChatBackends) ([]*chat.Backend, error) {
+	if agent.ServerURL == "" && agent.ChatModel == "" {
+		return selectChatBackends(backends.forConfig(cfg), agent.AgentDefinition)
+	}
+
+	if len(agent.UseBackends) > 0 {
+		return nil, fmt.Errorf("useBackends cannot be combined with serverURL or chatModel")
+	}
+
+	serverURL := agent.ServerURL
+	apiKey := agent.APIKey
+	model := cmp.Or(agent.ChatModel, cfg.ChatModel)
+
+	if agent.ServerURL == "" {
+		serverURL = cfg.ServerURL
+		apiKey = cmp.Or(agent.APIKey, cfg.APIKey)
+	}
+
+	if serverURL == "" || model == "" {
+		return nil, fmt.Errorf("no chat serverURL or model specified")
+	}
+
+	return []*chat.Backend{backends.derivedBackend(agent.Name, serverURL, apiKey, model)}, nil
+}
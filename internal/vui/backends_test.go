@@ -0,0 +1,152 @@
+package vui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/tools/mcp"
+	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAIServer emulates the OpenAI API endpoints used by the audio pipeline.
+type fakeAIServer struct {
+	*httptest.Server
+	transcription     string
+	chatDelay         time.Duration
+	inFlight          atomic.Int32
+	maxInFlight       atomic.Int32
+	sttModels         []string
+	chatRequestBodies []map[string]any
+	mutex             sync.Mutex
+}
+
+func newFakeAIServer(t *testing.T, transcription string) *fakeAIServer {
+	s := &fakeAIServer{transcription: transcription}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/v1/audio/transcriptions", func(w http.ResponseWriter, req *http.Request) {
+		s.mutex.Lock()
+		s.sttModels = append(s.sttModels, req.FormValue("model"))
+		s.mutex.Unlock()
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"text": s.transcription})
+	})
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, req *http.Request) {
+		body := map[string]any{}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+
+		s.mutex.Lock()
+		s.chatRequestBodies = append(s.chatRequestBodies, body)
+		s.mutex.Unlock()
+
+		inFlight := s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+
+		for {
+			maxInFlight := s.maxInFlight.Load()
+			if inFlight <= maxInFlight || s.maxInFlight.CompareAndSwap(maxInFlight, inFlight) {
+				break
+			}
+		}
+
+		time.Sleep(s.chatDelay)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hello there.\"}}]}\n\ndata: [DONE]\n\n")
+	})
+	mux.HandleFunc("/v1/audio/speech", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("fake wav"))
+	})
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// runPipeline sends the given input through a new audio pipeline and returns the text of the first spoken assistant response.
+func runPipeline(t *testing.T, ctx context.Context, cfg config.Configuration, backends *ChatBackends, channelID string, input AudioMessage) string {
+	conversation, err := NewConversation(cfg, channelID)
+	require.NoError(t, err)
+
+	inputCh := make(chan AudioMessage, 1)
+	inputCh <- input
+
+	output, err := AudioPipeline(ctx, cfg, mcp.Servers{}, backends, channelID, conversation, inputCh)
+	require.NoError(t, err)
+
+	for m := range output {
+		if !m.UserOnly {
+			return m.Text
+		}
+	}
+
+	t.Errorf("%s channel: pipeline terminated without response", channelID)
+
+	return ""
+}
+
+func TestAudioPipelinesShareChatBackends(t *testing.T) {
+	server := newFakeAIServer(t, "Computer, how are you?")
+	server.chatDelay = 200 * time.Millisecond
+
+	cfg := config.Configuration{
+		WakeWord: "Computer",
+		Backends: []config.ChatBackend{
+			{Name: "gpu", ServerURL: server.URL, Model: "fake-model", MaxConcurrency: 1},
+		},
+	}
+	cfg.ServerURL = server.URL
+
+	backends, err := NewChatBackends(cfg, http.DefaultClient)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	responses := make([]string, 2)
+	wg := sync.WaitGroup{}
+
+	for i, channelID := range []string{"kitchen", "living-room"} {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			responses[i] = runPipeline(t, ctx, cfg, backends, channelID, AudioMessage{WaveData: []byte("fake wav")})
+		}()
+	}
+
+	wg.Wait()
+
+	require.Equal(t, []string{"Hello there.", "Hello there."}, responses, "responses")
+	require.Len(t, server.chatRequestBodies, 2, "chat requests")
+	require.Equal(t, int32(1), server.maxInFlight.Load(), "max concurrent chat requests")
+}
+
+func TestChatBackendsDerivedBackendIsShared(t *testing.T) {
+	cfg := config.Configuration{}
+	cfg.ServerURL = "http://localai:8080"
+	cfg.ChatModel = "qwen3"
+
+	backends, err := NewChatBackends(cfg, http.DefaultClient)
+	require.NoError(t, err)
+
+	kitchen := backends.forConfig(cfg)
+	livingRoom := backends.forConfig(cfg)
+
+	require.Len(t, kitchen, 1)
+	require.Same(t, kitchen[0], livingRoom[0], "backend of the same server and model")
+
+	cfg.ChatModel = "llama3"
+
+	require.NotSame(t, kitchen[0], backends.forConfig(cfg)[0], "backend of another model")
+}
@@ -1,9 +1,123 @@
 package vui
 
 import (
+	"fmt"
+	"log/slog"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/chat"
+	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
 )
 
-func renderPromptTemplate(prompt, wakeWord string) string {
-	return strings.ReplaceAll(prompt, "{wakeWord}", wakeWord)
+// promptData holds the variables that are available within prompt templates.
+type promptData struct {
+	WakeWord  string
+	ChannelID string
+	Locale    string
+	Tools     []string
+	Vars      map[string]string
+	Now       time.Time
+	Date      string
+	Time      string
+	Timezone  string
+}
+
+// promptTemplate renders a prompt using the text/template syntax, e.g. "Today is {{.Date}}".
+// The legacy {wakeWord} placeholder is still supported.
+type promptTemplate struct {
+	template  *template.Template
+	wakeWord  string
+	channelID string
+	vars      map[string]string
+	location  *time.Location
+	now       func() time.Time
+	// lastRendered is the last successfully rendered prompt that is used when rendering fails.
+	lastRendered string
+	mutex        sync.Mutex
+}
+
+func newPromptTemplate(name, text string, cfg config.Configuration, channelID string) (*promptTemplate, error) {
+	location := time.Local
+	if cfg.Timezone != "" {
+		var err error
+
+		location, err = time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("load timezone: %w", err)
+		}
+	}
+
+	text = strings.ReplaceAll(text, "{wakeWord}", "{{.WakeWord}}")
+
+	tmpl, err := template.New(name).
+		Option("missingkey=zero").
+		Funcs(template.FuncMap{"join": strings.Join}).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s template: %w", name, err)
+	}
+
+	t := &promptTemplate{
+		template:  tmpl,
+		wakeWord:  cfg.WakeWord,
+		channelID: channelID,
+		vars:      cfg.PromptVars,
+		location:  location,
+		now:       time.Now,
+	}
+
+	// Render the template without request data to detect errors early.
+	t.lastRendered, err = t.render(chat.PromptData{})
+	if err != nil {
+		return nil, fmt.Errorf("render %s template: %w", name, err)
+	}
+
+	return t, nil
+}
+
+// Render renders the prompt using the current time along with the given request data.
+// When rendering fails, the last successfully rendered prompt is returned.
+func (t *promptTemplate) Render(data chat.PromptData) string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	rendered, err := t.render(data)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("failed to render %s template, using the previous prompt: %s", t.template.Name(), err))
+		return t.lastRendered
+	}
+
+	t.lastRendered = rendered
+
+	return rendered
+}
+
+func (t *promptTemplate) render(data chat.PromptData) (string, error) {
+	now := t.now().In(t.location)
+	timezone := t.location.String()
+	if t.location == time.Local {
+		timezone, _ = now.Zone()
+	}
+
+	b := strings.Builder{}
+
+	err := t.template.Execute(&b, promptData{
+		WakeWord:  t.wakeWord,
+		ChannelID: t.channelID,
+		Locale:    data.Locale,
+		Tools:     data.Tools,
+		Vars:      t.vars,
+		Now:       now,
+		Date:      now.Format("Monday, January 2, 2006"),
+		Time:      now.Format("15:04"),
+		Timezone:  timezone,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
 }
@@ -0,0 +1,75 @@
+package vui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/chat"
+	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptTemplate(t *testing.T) {
+	cfg := config.Configuration{
+		WakeWord:   "Computer",
+		Timezone:   "Europe/Berlin",
+		PromptVars: map[string]string{"city": "Berlin"},
+	}
+	data := chat.PromptData{Locale: "de-DE", Tools: []string{"get_weather", "set_volume"}}
+
+	for _, c := range []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "legacy wake word placeholder",
+			template: "Your name is {wakeWord}.",
+			expected: "Your name is Computer.",
+		},
+		{
+			name:     "date and time",
+			template: "It is {{.Time}} {{.Timezone}} on {{.Date}} ({{.Now.Format \"2006-01-02\"}}).",
+			expected: "It is 14:30 Europe/Berlin on Friday, May 17, 2024 (2024-05-17).",
+		},
+		{
+			name:     "request data",
+			template: "Channel {{.ChannelID}}, locale {{.Locale}}, tools: {{join .Tools \", \"}}.",
+			expected: "Channel kitchen, locale de-DE, tools: get_weather, set_volume.",
+		},
+		{
+			name:     "custom variables",
+			template: "You live in {{.Vars.city}}{{.Vars.undefined}}.",
+			expected: "You live in Berlin.",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			testee, err := newPromptTemplate("test", c.template, cfg, "kitchen")
+			require.NoError(t, err)
+
+			testee.now = func() time.Time {
+				return time.Date(2024, 5, 17, 12, 30, 0, 0, time.UTC)
+			}
+
+			require.Equal(t, c.expected, testee.Render(data))
+		})
+	}
+}
+
+func TestPromptTemplateInvalid(t *testing.T) {
+	for _, tmpl := range []string{
+		"Hello {{.WakeWord",
+		"Today is {{.Now.Weekday.Undefined}}.",
+	} {
+		_, err := newPromptTemplate("test", tmpl, config.Configuration{}, "")
+		require.Error(t, err, tmpl)
+	}
+}
+
+func TestPromptTemplateRenderFailure(t *testing.T) {
+	testee, err := newPromptTemplate("test", "Your tools: {{if .Tools}}{{index .Tools 1}}{{end}}", config.Configuration{}, "kitchen")
+	require.NoError(t, err)
+
+	require.Equal(t, "Your tools: set_volume", testee.Render(chat.PromptData{Tools: []string{"get_weather", "set_volume"}}))
+	require.Equal(t, "Your tools: set_volume", testee.Render(chat.PromptData{Tools: []string{"get_weather"}}), "should return the previously rendered prompt")
+}
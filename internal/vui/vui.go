@@ -1,9 +1,11 @@
 package vui
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -11,6 +13,7 @@ import (
 	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
 	"github.com/mgoltzsche/ai-assistant-vui/internal/soundgen"
 	"github.com/mgoltzsche/ai-assistant-vui/internal/stt"
+	"github.com/mgoltzsche/ai-assistant-vui/internal/tools"
 	"github.com/mgoltzsche/ai-assistant-vui/internal/tools/mcp"
 	"github.com/mgoltzsche/ai-assistant-vui/internal/tts"
 	"github.com/mgoltzsche/ai-assistant-vui/internal/wakeword"
@@ -19,64 +22,223 @@ import (
 
 type AudioMessage = model.AudioMessage
 
-func AudioPipeline(ctx context.Context, cfg config.Configuration, mcpServers mcp.Servers, input <-chan AudioMessage) (<-chan AudioMessage, *model.Conversation, error) {
+const defaultMaxTurns = 5
+
+// NewConversation creates a new conversation using the configured system prompt.
+// The system prompt is rendered again for every request.
+func NewConversation(cfg config.Configuration, channelID string) (*model.Conversation, error) {
+	systemPrompt, err := newPromptTemplate("prompt", strings.Join(cfg.Prompt, "\n"), cfg, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	return model.NewConversation(systemPrompt.Render(chat.PromptData{}), 1), nil
+}
+
+func AudioPipeline(ctx context.Context, cfg config.Configuration, mcpServers mcp.Servers, chatBackends *ChatBackends, channelID string, conversation *model.Conversation, input <-chan AudioMessage) (<-chan AudioMessage, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	go func() {
 		<-ctx.Done()
 		cancel()
 	}()
 
-	systemPrompt := renderPromptTemplate(strings.Join(cfg.Prompt, "\n"), cfg.WakeWord)
-	conversation := model.NewConversation(systemPrompt, 1)
-	tools, err := mcp.ToolProvider(mcpServers, cfg.Tools)
+	conversation.SetHistoryPolicy(model.HistoryPolicy{
+		MaxRequests:   cfg.History.MaxRequests,
+		MaxTokens:     cfg.History.MaxTokens,
+		KeepToolCalls: cfg.History.KeepToolCalls,
+		Summarize:     cfg.History.Summarize,
+	})
+
+	switch chat.ToolCallMode(cfg.ToolCallMode) {
+	case "", chat.ToolCallModeNative, chat.ToolCallModeLegacy:
+	default:
+		return nil, fmt.Errorf("unsupported tool call mode %q", cfg.ToolCallMode)
+	}
+
+	mainTools, err := mcp.ToolProvider(mcpServers, cfg.Tools)
 	if err != nil {
-		return nil, nil, fmt.Errorf("init main tools: %w", err)
+		return nil, fmt.Errorf("init main tools: %w", err)
 	}
 
 	wakewordFilter := &wakeword.Filter{
 		WakeWord: cfg.WakeWord,
 	}
 	httpClient := &http.Client{Timeout: 90 * time.Second}
+	audioInputMode, err := chat.ParseAudioInputMode(cfg.AudioInputMode)
+	if err != nil {
+		return nil, err
+	}
+
+	sttModel := cfg.STTModel
+	if audioInputMode == chat.AudioInputModeDirect && cfg.WakeWordSTTModel != "" {
+		sttModel = cfg.WakeWordSTTModel // cheap model, only used to detect the wake word
+	}
+
 	transcriber := &stt.Transcriber{
 		Service: &stt.Client{
 			URL:    cfg.ServerURL,
-			Model:  cfg.STTModel,
+			Model:  sttModel,
 			Client: httpClient,
 		},
 	}
-	requester := &chat.Requester{}
+	requester := &chat.Requester{
+		AudioInputMode: audioInputMode,
+	}
+	mainBackends, err := selectChatBackends(chatBackends.forConfig(cfg), cfg.AgentDefinition)
+	if err != nil {
+		return nil, err
+	}
+
+	thinkingMode, err := chat.ParseThinkingMode(cfg.ThinkingMode)
+	if err != nil {
+		return nil, err
+	}
+
+	agentOrchestration, err := chat.ParseAgentOrchestration(cfg.AgentOrchestration)
+	if err != nil {
+		return nil, err
+	}
+
 	llm := chat.LLM{
-		ServerURL:           cfg.ServerURL,
-		APIKey:              cfg.APIKey,
-		Model:               cfg.ChatModel,
+		Backends:            mainBackends,
+		Routing:             chat.RoutingStrategy(cfg.Routing),
 		Temperature:         cfg.Temperature,
 		FrequencyPenalty:    1.5,
-		MaxTokens:           0,
+		MaxTokens:           cfg.MaxTokens,
 		StripResponsePrefix: fmt.Sprintf("%s:", wakewordFilter.WakeWord),
-		MaxTurns:            5,
-		HTTPClient:          httpClient,
+		MaxTurns:            cmp.Or(cfg.MaxTurns, defaultMaxTurns),
+		ToolCallMode:        chat.ToolCallMode(cfg.ToolCallMode),
+		ToolChoice:          cfg.ToolChoice,
+		DefaultThinkingMode: thinkingMode,
+		AgentOrchestration:  agentOrchestration,
+		Usage:               conversation,
+		LoopPrevention: tools.LoopPolicy{
+			MaxIdenticalCalls: cfg.LoopPrevention.MaxIdenticalCalls,
+			MaxCallsPerTool:   cfg.LoopPrevention.MaxCallsPerTool,
+			MaxCalls:          cfg.LoopPrevention.MaxCalls,
+			MaxRejections:     cfg.LoopPrevention.MaxRejections,
+		},
+		Retry: chat.RetryPolicy{
+			MaxAttempts:    cfg.Retry.MaxAttempts,
+			InitialBackoff: time.Duration(cfg.Retry.InitialBackoff),
+			MaxBackoff:     time.Duration(cfg.Retry.MaxBackoff),
+		},
+		ToolExecution: chat.ToolExecutionPolicy{
+			MaxConcurrency: cfg.ToolExecution.MaxConcurrency,
+			Timeout:        time.Duration(cfg.ToolExecution.Timeout),
+			Timeouts:       make(map[string]time.Duration, len(cfg.ToolExecution.Timeouts)),
+		},
+	}
+	for name, timeout := range cfg.ToolExecution.Timeouts {
+		llm.ToolExecution.Timeouts[name] = time.Duration(timeout)
 	}
+
 	agents := make([]chat.Agent, len(cfg.Agents))
 	for i, a := range cfg.Agents {
 		agentTools, err := mcp.ToolProvider(mcpServers, a.Tools)
 		if err != nil {
-			return nil, nil, fmt.Errorf("init %s agent tools: %w", a.Name, err)
+			return nil, fmt.Errorf("init %s agent tools: %w", a.Name, err)
+		}
+
+		agentLLM := llm
+		agentLLM.Agent = a.Name
+
+		if len(a.UseBackends) > 0 || a.ServerURL != "" || a.ChatModel != "" {
+			agentLLM.Backends, err = agentChatBackends(cfg, a, chatBackends)
+			if err != nil {
+				return nil, fmt.Errorf("agent %s: %w", a.Name, err)
+			}
+
+			agentLLM.Routing = chat.RoutingStrategy(a.Routing)
+		}
+
+		if a.Temperature != nil {
+			agentLLM.Temperature = *a.Temperature
+		}
+
+		if a.MaxTokens > 0 {
+			agentLLM.MaxTokens = a.MaxTokens
+		}
+
+		if a.MaxTurns > 0 {
+			agentLLM.MaxTurns = a.MaxTurns
+		}
+
+		if a.TTSVoice != "" {
+			agentLLM.Voice = a.TTSVoice
+		}
+
+		if a.ThinkingMode != "" {
+			agentLLM.ThinkingMode, err = chat.ParseThinkingMode(a.ThinkingMode)
+			if err != nil {
+				return nil, fmt.Errorf("agent %s: %w", a.Name, err)
+			}
+		}
+
+		resultMode, err := chat.ParseAgentResultMode(a.ResultMode)
+		if err != nil {
+			return nil, fmt.Errorf("agent %s: %w", a.Name, err)
+		}
+
+		agentPrompt, err := newPromptTemplate(a.Name+" agent prompt", strings.Join(a.Prompt, "\n"), cfg, channelID)
+		if err != nil {
+			return nil, fmt.Errorf("agent %s: %w", a.Name, err)
 		}
 
 		agents[i] = chat.Agent{
 			Name:         a.Name,
 			Description:  a.Description,
 			Tools:        agentTools,
-			SystemPrompt: renderPromptTemplate(strings.Join(a.Prompt, "\n"), cfg.WakeWord),
-			LLM:          llm,
+			SystemPrompt: agentPrompt.Render,
+			LLM:          agentLLM,
+			ResultMode:   resultMode,
+			MaxDepth:     cfg.MaxAgentDepth,
 		}
 	}
 
+	for i, a := range cfg.Agents {
+		agents[i].Agents, err = selectAgents(agents, a.UseAgents)
+		if err != nil {
+			return nil, fmt.Errorf("agent %s: %w", a.Name, err)
+		}
+	}
+
+	// The main dialog can use all agents unless it references particular ones.
+	mainAgents := make([]*chat.Agent, len(agents))
+	for i := range agents {
+		mainAgents[i] = &agents[i]
+	}
+
+	if len(cfg.UseAgents) > 0 {
+		mainAgents, err = selectAgents(agents, cfg.UseAgents)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	systemPrompt, err := newPromptTemplate("prompt", strings.Join(cfg.Prompt, "\n"), cfg, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	var introPrompt chat.Prompt
+	if cfg.IntroPrompt != "" && conversation.RequestCounter() <= 1 {
+		// Don't greet the user again when continuing a restored conversation.
+		tmpl, err := newPromptTemplate("introPrompt", cfg.IntroPrompt, cfg, channelID)
+		if err != nil {
+			return nil, err
+		}
+
+		introPrompt = tmpl.Render
+	}
+
 	chatCompleter := &chat.Completer{
-		LLM:         llm,
-		Tools:       tools,
-		IntroPrompt: renderPromptTemplate(cfg.IntroPrompt, cfg.WakeWord),
-		Agents:      agents,
+		LLM:              llm,
+		Tools:            mainTools,
+		SystemPrompt:     systemPrompt.Render,
+		IntroPrompt:      introPrompt,
+		Agents:           mainAgents,
+		DailyTokenBudget: cfg.Budget.DailyTokens,
 	}
 	/*conversationAgent := &chat.ConversationAgent{
 		Completer: chatCompleter,
@@ -88,24 +250,42 @@ func AudioPipeline(ctx context.Context, cfg config.Configuration, mcpServers mcp
 		Service: &tts.Client{
 			URL:    cfg.ServerURL,
 			Model:  cfg.TTSModel,
+			Voice:  cfg.TTSVoice,
 			Client: httpClient,
 		},
 	}
+	if cfg.TTSNormalization.Enabled {
+		speechGen.Normalizer, err = tts.NewNormalizer(cfg.TTSNormalization.Language, tts.URLMode(cfg.TTSNormalization.URLs))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	soundGen := &soundgen.Generator{
 		SampleRate: 16000,
 	}
 
-	transcriptions := transcriber.Transcribe(ctx, input)
-	userRequests := wakewordFilter.FilterByWakeWord(transcriptions)
-	userRequestsConverted := chat.ToAudioMessageStreamWithoutAudioData(userRequests)
-	completionRequests, notifications := requester.AddUserRequestsToConversation(ctx, userRequestsConverted, conversation)
+	var userRequests <-chan AudioMessage
+
+	switch {
+	case audioInputMode == chat.AudioInputModeDirect && cfg.WakeWord == "":
+		userRequests = input
+	case audioInputMode == chat.AudioInputModeDirect:
+		// Transcribe the audio for wake word detection only
+		userRequests = wakewordFilter.FilterAudioByWakeWord(transcriber.TranscribeAudio(ctx, input))
+	default:
+		transcriptions := transcriber.Transcribe(ctx, input)
+		userRequests = chat.ToAudioMessageStreamWithoutAudioData(wakewordFilter.FilterByWakeWord(transcriptions))
+	}
+
+	completionRequests, notifications := requester.AddUserRequestsToConversation(ctx, userRequests, conversation)
 
 	responses, err := chatCompleter.Run(ctx, completionRequests, conversation)
 	if err != nil {
 		cancel()
 		for _ = range responses {
 		}
-		return nil, nil, err
+		return nil, err
 	}
 
 	notificationSounds, err := soundGen.Notify(notifications, conversation)
@@ -115,12 +295,28 @@ func AudioPipeline(ctx context.Context, cfg config.Configuration, mcpServers mcp
 		}
 		for _ = range notificationSounds {
 		}
-		return nil, nil, err
+		return nil, err
 	}
 
 	responses = chat.ChunksToSentences(responses)
 	speeches := speechGen.GenerateAudio(ctx, responses, conversation)
 	audioOutput := chat.MergeChannels(speeches, notificationSounds)
 
-	return audioOutput, conversation, nil
+	return audioOutput, nil
+}
+
+// selectAgents returns the agents with the given names.
+func selectAgents(agents []chat.Agent, names []string) ([]*chat.Agent, error) {
+	selected := make([]*chat.Agent, 0, len(names))
+
+	for _, name := range names {
+		i := slices.IndexFunc(agents, func(a chat.Agent) bool { return a.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("agent %q is not defined", name)
+		}
+
+		selected = append(selected, &agents[i])
+	}
+
+	return selected, nil
 }
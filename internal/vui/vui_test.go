@@ -0,0 +1,73 @@
+package vui
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mgoltzsche/ai-assistant-vui/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudioPipelineAudioInputMode(t *testing.T) {
+	for _, c := range []struct {
+		name             string
+		audioInputMode   string
+		wakeWord         string
+		wakeWordSTTModel string
+		expectSTTModels  []string
+		expectContent    any
+	}{
+		{
+			name:             "transcribe",
+			wakeWord:         "Computer",
+			wakeWordSTTModel: "whisper-tiny",
+			expectSTTModels:  []string{"whisper-large"},
+			expectContent:    "Computer, how are you? ",
+		},
+		{
+			name:             "direct with wake word",
+			audioInputMode:   "direct",
+			wakeWord:         "Computer",
+			wakeWordSTTModel: "whisper-tiny",
+			expectSTTModels:  []string{"whisper-tiny"},
+			expectContent:    []any{map[string]any{"type": "input_audio", "input_audio": map[string]any{"data": "ZmFrZSB3YXY=", "format": "wav"}}},
+		},
+		{
+			name:           "direct without wake word",
+			audioInputMode: "direct",
+			expectContent:  []any{map[string]any{"type": "input_audio", "input_audio": map[string]any{"data": "ZmFrZSB3YXY=", "format": "wav"}}},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			server := newFakeAIServer(t, "Computer, how are you?")
+			cfg := config.Configuration{
+				WakeWord:         c.wakeWord,
+				AudioInputMode:   c.audioInputMode,
+				STTModel:         "whisper-large",
+				WakeWordSTTModel: c.wakeWordSTTModel,
+			}
+			cfg.ServerURL = server.URL
+			cfg.ChatModel = "fake-model"
+
+			backends, err := NewChatBackends(cfg, http.DefaultClient)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			response := runPipeline(t, ctx, cfg, backends, "kitchen", AudioMessage{WaveData: []byte("fake wav")})
+
+			require.Equal(t, "Hello there.", response, "response")
+			require.Equal(t, c.expectSTTModels, server.sttModels, "STT models")
+			require.Len(t, server.chatRequestBodies, 1, "chat requests")
+
+			messages, _ := server.chatRequestBodies[0]["messages"].([]any)
+			require.NotEmpty(t, messages, "chat request messages")
+
+			userRequest, _ := messages[len(messages)-1].(map[string]any)
+			require.Equal(t, c.expectContent, userRequest["content"], "user request content")
+		})
+	}
+}
@@ -15,25 +15,57 @@ import (
 // * https://community.rhasspy.org/t/openwakeword-new-library-and-pre-trained-models-for-wakeword-and-phrase-detection/4162
 
 type Message = model.Message
+type AudioMessage = model.AudioMessage
 
 type Filter struct {
 	WakeWord string
 }
 
+func (f *Filter) regex() *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(`(?i)(^|[^\w])%[1]s($|[^\w])`, regexp.QuoteMeta(f.WakeWord)))
+}
+
+// FilterByWakeWord forwards the transcribed requests that contain the wake word.
 func (f *Filter) FilterByWakeWord(requests <-chan Message) <-chan Message {
-	regex := regexp.MustCompile(fmt.Sprintf(`(?i)(^|[^\w])%[1]s($|[^\w])`, regexp.QuoteMeta(f.WakeWord)))
+	return filter(f.regex(), requests, func(m *Message) *Message { return m })
+}
 
-	ch := make(chan Message, 5)
+// FilterAudioByWakeWord filters transcribed audio messages by wake word, keeping their audio data.
+func (f *Filter) FilterAudioByWakeWord(requests <-chan AudioMessage) <-chan AudioMessage {
+	return filter(f.regex(), requests, func(m *AudioMessage) *Message { return &m.Message })
+}
+
+// filter forwards the requests that contain the wake word.
+// Images attached to other requests are attached to the next forwarded request instead of dropping them.
+func filter[T any](regex *regexp.Regexp, requests <-chan T, message func(*T) *Message) <-chan T {
+	ch := make(chan T, 5)
 
 	go func() {
 		defer close(ch)
 
+		var pendingImages []model.Image
+
 		for req := range requests {
-			if regex.MatchString(req.Text) {
-				ch <- req
-			} else {
-				slog.Info(fmt.Sprintf("user: %s", req.Text))
+			msg := message(&req)
+
+			if !regex.MatchString(msg.Text) {
+				slog.Info(fmt.Sprintf("user: %s", msg.Text))
+
+				if len(msg.Images) > 0 {
+					slog.Info(fmt.Sprintf("keeping %d images for the next request that contains the wake word", len(msg.Images)))
+
+					pendingImages = model.AppendPendingImages(pendingImages, msg.Images...)
+				}
+
+				continue
 			}
+
+			if len(pendingImages) > 0 {
+				msg.Images = model.AppendPendingImages(pendingImages, msg.Images...)
+				pendingImages = nil
+			}
+
+			ch <- req
 		}
 	}()
 
@@ -0,0 +1,65 @@
+package wakeword
+
+import (
+	"testing"
+
+	"github.com/mgoltzsche/ai-assistant-vui/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterByWakeWord(t *testing.T) {
+	testee := &Filter{WakeWord: "Computer"}
+	texts := []string{"Computer, how are you?", "Hello there.", "Thanks, computer!", "Computers are great."}
+	expected := []string{"Computer, how are you?", "Thanks, computer!"}
+
+	t.Run("text", func(t *testing.T) {
+		requests := make(chan Message, len(texts))
+		for _, text := range texts {
+			requests <- Message{Text: text}
+		}
+		close(requests)
+
+		actual := []string{}
+		for m := range testee.FilterByWakeWord(requests) {
+			actual = append(actual, m.Text)
+		}
+
+		require.Equal(t, expected, actual)
+	})
+
+	t.Run("audio", func(t *testing.T) {
+		requests := make(chan AudioMessage, len(texts))
+		for _, text := range texts {
+			requests <- AudioMessage{Message: Message{Text: text}, WaveData: []byte(text)}
+		}
+		close(requests)
+
+		actual := []string{}
+		for m := range testee.FilterAudioByWakeWord(requests) {
+			require.Equal(t, m.Text, string(m.WaveData), "audio data")
+
+			actual = append(actual, m.Text)
+		}
+
+		require.Equal(t, expected, actual)
+	})
+}
+
+func TestFilterByWakeWordKeepsImages(t *testing.T) {
+	testee := &Filter{WakeWord: "Computer"}
+	requests := make(chan Message, 3)
+	requests <- Message{Text: "Look at this.", Images: []model.Image{{MIMEType: "image/png", Data: []byte("plant")}}}
+	requests <- Message{Text: "Computer, what's this plant?", Images: []model.Image{{MIMEType: "image/jpeg", Data: []byte("leaf")}}}
+	requests <- Message{Text: "Computer, thanks!"}
+	close(requests)
+
+	actual := []Message{}
+	for m := range testee.FilterByWakeWord(requests) {
+		actual = append(actual, m)
+	}
+
+	require.Equal(t, []Message{
+		{Text: "Computer, what's this plant?", Images: []model.Image{{MIMEType: "image/png", Data: []byte("plant")}, {MIMEType: "image/jpeg", Data: []byte("leaf")}}},
+		{Text: "Computer, thanks!"},
+	}, actual)
+}
@@ -7,27 +7,114 @@ import (
 )
 
 type Configuration struct {
-	ServerURL    string               `json:"serverURL"`
-	APIKey       string               `json:"apiKey"`
-	InputDevice  string               `json:"inputDevice,omitempty"`
-	OutputDevice string               `json:"outputDevice,omitempty"`
-	MinVolume    int                  `json:"minVolume,omitempty"`
-	VADEnabled   bool                 `json:"vadEnabled,omitempty"`
-	VADModelPath string               `json:"vadModelPath,omitempty"`
-	STTModel     string               `json:"sttModel,omitempty"`
-	TTSModel     string               `json:"ttsModel,omitempty"`
-	ChatModel    string               `json:"chatModel,omitempty"`
-	Temperature  float64              `json:"temperature,omitempty"`
-	WakeWord     string               `json:"wakeWord,omitempty"`
-	IntroPrompt  string               `json:"introPrompt,omitempty"`
-	MCPServers   map[string]MCPServer `json:"mcpServers,omitempty"`
-	Agents       []AgentDefinition    `json:"agents,omitempty"`
+	ServerURL          string               `json:"serverURL"`
+	APIKey             string               `json:"apiKey"`
+	InputDevice        string               `json:"inputDevice,omitempty"`
+	OutputDevice       string               `json:"outputDevice,omitempty"`
+	MinVolume          int                  `json:"minVolume,omitempty"`
+	VADEnabled         bool                 `json:"vadEnabled,omitempty"`
+	VADModelPath       string               `json:"vadModelPath,omitempty"`
+	STTModel           string               `json:"sttModel,omitempty"`
+	AudioInputMode     string               `json:"audioInputMode,omitempty"`
+	WakeWordSTTModel   string               `json:"wakeWordSTTModel,omitempty"`
+	TTSModel           string               `json:"ttsModel,omitempty"`
+	TTSNormalization   TTSNormalization     `json:"ttsNormalization,omitempty"`
+	ChatModel          string               `json:"chatModel,omitempty"`
+	Temperature        float64              `json:"temperature,omitempty"`
+	ToolCallMode       string               `json:"toolCallMode,omitempty"`
+	ToolChoice         string               `json:"toolChoice,omitempty"`
+	AgentOrchestration string               `json:"agentOrchestration,omitempty"`
+	WakeWord           string               `json:"wakeWord,omitempty"`
+	IntroPrompt        string               `json:"introPrompt,omitempty"`
+	PromptVars         map[string]string    `json:"promptVars,omitempty"`
+	Timezone           string               `json:"timezone,omitempty"`
+	ConversationDir    string               `json:"conversationDir,omitempty"`
+	History            HistoryPolicy        `json:"history,omitempty"`
+	Retry              RetryPolicy          `json:"retry,omitempty"`
+	ToolExecution      ToolExecutionPolicy  `json:"toolExecution,omitempty"`
+	Budget             BudgetPolicy         `json:"budget,omitempty"`
+	LoopPrevention     LoopPolicy           `json:"loopPrevention,omitempty"`
+	Backends           []ChatBackend        `json:"backends,omitempty"`
+	MCPServers         map[string]MCPServer `json:"mcpServers,omitempty"`
+	MCPSupervision     MCPSupervisionPolicy `json:"mcpSupervision,omitempty"`
+	Agents             []Agent              `json:"agents,omitempty"`
+	MaxAgentDepth      int                  `json:"maxAgentDepth,omitempty"`
+	Profiles           map[string]Profile   `json:"profiles,omitempty"`
+	// ChannelProfiles maps channel IDs to the profiles they use by default.
+	ChannelProfiles map[string]string `json:"channelProfiles,omitempty"`
 	AgentDefinition
 }
 
+type TTSNormalization struct {
+	Enabled  bool   `json:"enabled,omitempty"`
+	Language string `json:"language,omitempty"`
+	URLs     string `json:"urls,omitempty"`
+}
+
+type HistoryPolicy struct {
+	MaxRequests   int  `json:"maxRequests,omitempty"`
+	MaxTokens     int  `json:"maxTokens,omitempty"`
+	KeepToolCalls bool `json:"keepToolCalls,omitempty"`
+	Summarize     bool `json:"summarize,omitempty"`
+}
+
+type RetryPolicy struct {
+	MaxAttempts    int      `json:"maxAttempts,omitempty"`
+	InitialBackoff Duration `json:"initialBackoff,omitempty"`
+	MaxBackoff     Duration `json:"maxBackoff,omitempty"`
+}
+
+type ToolExecutionPolicy struct {
+	MaxConcurrency int                 `json:"maxConcurrency,omitempty"`
+	Timeout        Duration            `json:"timeout,omitempty"`
+	Timeouts       map[string]Duration `json:"timeouts,omitempty"`
+}
+
+type LoopPolicy struct {
+	MaxIdenticalCalls int            `json:"maxIdenticalCalls,omitempty"`
+	MaxCallsPerTool   int            `json:"maxCallsPerTool,omitempty"`
+	MaxCalls          map[string]int `json:"maxCalls,omitempty"`
+	MaxRejections     int            `json:"maxRejections,omitempty"`
+}
+
+type BudgetPolicy struct {
+	DailyTokens int `json:"dailyTokens,omitempty"`
+}
+
+type ChatBackend struct {
+	Name           string `json:"name"`
+	ServerURL      string `json:"serverURL"`
+	APIKey         string `json:"apiKey,omitempty"`
+	Model          string `json:"model"`
+	MaxTokens      int    `json:"maxTokens,omitempty"`
+	MaxConcurrency int    `json:"maxConcurrency,omitempty"`
+	ThinkingMode   string `json:"thinkingMode,omitempty"`
+}
+
+type MCPSupervisionPolicy struct {
+	HealthCheckInterval Duration `json:"healthCheckInterval,omitempty"`
+	InitialBackoff      Duration `json:"initialBackoff,omitempty"`
+	MaxBackoff          Duration `json:"maxBackoff,omitempty"`
+}
+
 type MCPServer struct {
-	Command string   `json:"command"`
-	Args    []string `json:"args,omitempty"`
+	Command    string   `json:"command,omitempty"`
+	Args       []string `json:"args,omitempty"`
+	WorkingDir string   `json:"workingDir,omitempty"`
+	// Env specifies environment variables of the server process in addition to the inherited ones.
+	// Values may reference the assistant's environment variables as ${VAR}.
+	Env map[string]string `json:"env,omitempty"`
+	// EnvFrom lists env files containing KEY=VALUE lines.
+	EnvFrom []string `json:"envFrom,omitempty"`
+	// SecretFiles maps environment variable names to files containing their values, e.g. mounted secrets.
+	SecretFiles map[string]string `json:"secretFiles,omitempty"`
+	// URL is the endpoint of a remote MCP server, used instead of a command.
+	URL string `json:"url,omitempty"`
+	// Transport is the transport of a remote MCP server: streamable (default) or sse.
+	Transport string `json:"transport,omitempty"`
+	// Headers and BearerToken are sent to a remote MCP server. Their values may reference environment variables as ${VAR}.
+	Headers     map[string]string `json:"headers,omitempty"`
+	BearerToken string            `json:"bearerToken,omitempty"`
 }
 
 type FunctionDefinition struct {
@@ -44,10 +131,32 @@ type Container struct {
 }
 
 type AgentDefinition struct {
-	Name        string              `json:"name,omitempty"`
-	Description string              `json:"description,omitempty"`
-	Prompt      []string            `json:"prompt,omitempty"`
-	Tools       []MCPToolsReference `json:"tools,omitempty"`
+	Name         string              `json:"name,omitempty"`
+	Description  string              `json:"description,omitempty"`
+	Prompt       []string            `json:"prompt,omitempty"`
+	Tools        []MCPToolsReference `json:"tools,omitempty"`
+	UseBackends  []string            `json:"useBackends,omitempty"`
+	Routing      string              `json:"routing,omitempty"`
+	UseAgents    []string            `json:"useAgents,omitempty"`
+	ThinkingMode string              `json:"thinkingMode,omitempty"`
+	ResultMode   string              `json:"resultMode,omitempty"`
+	MaxTokens    int                 `json:"maxTokens,omitempty"`
+	MaxTurns     int                 `json:"maxTurns,omitempty"`
+	TTSVoice     string              `json:"ttsVoice,omitempty"`
+}
+
+// Agent is an agent the main dialog or other agents can delegate requests to.
+type Agent struct {
+	AgentDefinition
+	AgentOverrides
+}
+
+// AgentOverrides are settings of an agent that override the corresponding top-level settings.
+type AgentOverrides struct {
+	ServerURL   string   `json:"serverURL,omitempty"`
+	APIKey      string   `json:"apiKey,omitempty"`
+	ChatModel   string   `json:"chatModel,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
 }
 
 type MCPToolsReference struct {
@@ -24,18 +24,33 @@ func FromFile(path string) (Configuration, error) {
 		return cfg, fmt.Errorf("read config at %s: %w", path, err)
 	}
 
-	b, err = json.Marshal(m)
+	err = decode(m, &cfg)
 	if err != nil {
-		return cfg, fmt.Errorf("load config: marshal config: %w", err)
+		return cfg, fmt.Errorf("read config at %s: %w", path, err)
 	}
 
-	d := json.NewDecoder(bytes.NewReader(b))
-	d.DisallowUnknownFields()
+	err = cfg.validateAgents()
+	if err != nil {
+		return cfg, fmt.Errorf("invalid config at %s: %w", path, err)
+	}
 
-	err = d.Decode(&cfg)
+	err = cfg.validateProfiles()
 	if err != nil {
-		return cfg, fmt.Errorf("read config at %s: %w", path, err)
+		return cfg, fmt.Errorf("invalid config at %s: %w", path, err)
 	}
 
 	return cfg, nil
 }
+
+// decode decodes the given generic configuration, rejecting unknown fields.
+func decode(m map[string]any, cfg *Configuration) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("marshal config: %w", err)
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+
+	return d.Decode(cfg)
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeAgentOverrides(t *testing.T) {
+	var cfg Configuration
+
+	err := decode(map[string]any{
+		"serverURL":   "http://localai:8080",
+		"chatModel":   "qwen3",
+		"temperature": 0.7,
+		"maxTurns":    5,
+		"agents": []any{
+			map[string]any{
+				"name":        "search",
+				"chatModel":   "qwen3-4b",
+				"temperature": 0.2,
+				"maxTurns":    3,
+			},
+		},
+	}, &cfg)
+	require.NoError(t, err)
+
+	temperature := 0.2
+
+	require.Equal(t, "http://localai:8080", cfg.ServerURL, "serverURL")
+	require.Equal(t, "qwen3", cfg.ChatModel, "chatModel")
+	require.Equal(t, 0.7, cfg.Temperature, "temperature")
+	require.Equal(t, 5, cfg.MaxTurns, "maxTurns")
+	require.Equal(t, []Agent{{
+		AgentDefinition: AgentDefinition{Name: "search", MaxTurns: 3},
+		AgentOverrides:  AgentOverrides{ChatModel: "qwen3-4b", Temperature: &temperature},
+	}}, cfg.Agents, "agents")
+}
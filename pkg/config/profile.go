@@ -0,0 +1,93 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Profile is a named set of settings that override the top-level configuration for particular channels,
+// e.g. a different prompt, wake word, TTS voice or tools.
+// It is specified using the same fields as the top-level configuration.
+type Profile map[string]any
+
+// profileExcludedFields are top-level fields that cannot be overridden by a profile
+// since they are shared by all channels.
+var profileExcludedFields = []string{"mcpServers", "mcpSupervision", "backends", "conversationDir", "profiles", "channelProfiles"}
+
+// WithProfile returns the configuration with the settings of the given profile applied.
+// Nested objects are merged while lists and other values are replaced.
+func (c Configuration) WithProfile(name string) (Configuration, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return c, fmt.Errorf("profile %q is not defined", name)
+	}
+
+	for _, field := range profileExcludedFields {
+		if _, ok := profile[field]; ok {
+			return c, fmt.Errorf("profile %s: field %s cannot be overridden by a profile", name, field)
+		}
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return c, fmt.Errorf("profile %s: marshal config: %w", name, err)
+	}
+
+	m := map[string]any{}
+
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return c, fmt.Errorf("profile %s: unmarshal config: %w", name, err)
+	}
+
+	var merged Configuration
+
+	err = decode(mergeMaps(m, profile), &merged)
+	if err != nil {
+		return c, fmt.Errorf("profile %s: %w", name, err)
+	}
+
+	return merged, nil
+}
+
+func mergeMaps(dst, src map[string]any) map[string]any {
+	merged := maps.Clone(dst)
+
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[string]any)
+		dstMap, dstIsMap := merged[k].(map[string]any)
+
+		if srcIsMap && dstIsMap {
+			merged[k] = mergeMaps(dstMap, srcMap)
+		} else {
+			merged[k] = v
+		}
+	}
+
+	return merged
+}
+
+// validateProfiles verifies that the profiles can be applied and that channels reference existing profiles.
+func (c *Configuration) validateProfiles() error {
+	for _, name := range slices.Sorted(maps.Keys(c.Profiles)) {
+		cfg, err := c.WithProfile(name)
+		if err != nil {
+			return err
+		}
+
+		err = cfg.validateAgents()
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+
+	for channelID, name := range c.ChannelProfiles {
+		if _, ok := c.Profiles[name]; !ok {
+			return fmt.Errorf("channel %s: profile %q is not defined", channelID, name)
+		}
+	}
+
+	return nil
+}
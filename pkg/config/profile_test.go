@@ -0,0 +1,48 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithProfile(t *testing.T) {
+	cfg := Configuration{
+		ChatModel: "qwen3-4b",
+		WakeWord:  "Computer",
+		History:   HistoryPolicy{MaxRequests: 5, MaxTokens: 4000},
+		Retry:     RetryPolicy{InitialBackoff: Duration(500_000_000)},
+		Profiles: map[string]Profile{
+			"kids": {
+				"wakeWord": "Robo",
+				"history":  map[string]any{"maxRequests": 2},
+				"prompt":   []any{"You are talking to children."},
+			},
+			"invalid": {"unknownField": true},
+			"shared":  {"mcpServers": map[string]any{}},
+		},
+		AgentDefinition: AgentDefinition{
+			Prompt:   []string{"You are a helpful assistant."},
+			TTSVoice: "alloy",
+		},
+	}
+
+	actual, err := cfg.WithProfile("kids")
+	require.NoError(t, err)
+
+	expected := cfg
+	expected.WakeWord = "Robo"
+	expected.History.MaxRequests = 2
+	expected.Prompt = []string{"You are talking to children."}
+	expected.Profiles, actual.Profiles = nil, nil
+	require.Equal(t, expected, actual)
+
+	_, err = cfg.WithProfile("undefined")
+	require.Error(t, err, "undefined profile")
+
+	_, err = cfg.WithProfile("invalid")
+	require.Error(t, err, "unknown field")
+
+	_, err = cfg.WithProfile("shared")
+	require.Error(t, err, "excluded field")
+}
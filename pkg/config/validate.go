@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateAgents verifies that agent names are unique and that agents reference existing agents without cycles.
+func (c *Configuration) validateAgents() error {
+	agents := make(map[string]Agent, len(c.Agents))
+
+	for i, a := range c.Agents {
+		if a.Name == "" {
+			return fmt.Errorf("agents[%d]: no name specified", i)
+		}
+
+		if _, ok := agents[a.Name]; ok {
+			return fmt.Errorf("duplicate agent name %q", a.Name)
+		}
+
+		agents[a.Name] = a
+	}
+
+	for _, a := range c.Agents {
+		for _, ref := range a.UseAgents {
+			if _, ok := agents[ref]; !ok {
+				return fmt.Errorf("agent %s: referenced agent %q is not defined", a.Name, ref)
+			}
+		}
+	}
+
+	visited := make(map[string]bool, len(agents))
+
+	var visit func(name string, path []string) error
+
+	visit = func(name string, path []string) error {
+		for i, p := range path {
+			if p == name {
+				return fmt.Errorf("agent reference cycle detected: %s", strings.Join(append(path[i:], name), " -> "))
+			}
+		}
+
+		if visited[name] {
+			return nil
+		}
+
+		path = append(path, name)
+
+		for _, ref := range agents[name].UseAgents {
+			err := visit(ref, path)
+			if err != nil {
+				return err
+			}
+		}
+
+		visited[name] = true
+
+		return nil
+	}
+
+	for _, a := range c.Agents {
+		err := visit(a.Name, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAgents(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		agents      []AgentDefinition
+		expectError string
+	}{
+		{
+			name: "hierarchy",
+			agents: []AgentDefinition{
+				{Name: "home", UseAgents: []string{"music", "light"}},
+				{Name: "music", UseAgents: []string{"playlist"}},
+				{Name: "light"},
+				{Name: "playlist"},
+			},
+		},
+		{
+			name: "shared agent",
+			agents: []AgentDefinition{
+				{Name: "home", UseAgents: []string{"music", "search"}},
+				{Name: "music", UseAgents: []string{"search"}},
+				{Name: "search"},
+			},
+		},
+		{
+			name: "undefined agent",
+			agents: []AgentDefinition{
+				{Name: "home", UseAgents: []string{"music"}},
+			},
+			expectError: `agent home: referenced agent "music" is not defined`,
+		},
+		{
+			name: "duplicate agent",
+			agents: []AgentDefinition{
+				{Name: "home"},
+				{Name: "home"},
+			},
+			expectError: `duplicate agent name "home"`,
+		},
+		{
+			name: "self reference",
+			agents: []AgentDefinition{
+				{Name: "home", UseAgents: []string{"home"}},
+			},
+			expectError: "agent reference cycle detected: home -> home",
+		},
+		{
+			name: "cycle",
+			agents: []AgentDefinition{
+				{Name: "home", UseAgents: []string{"music"}},
+				{Name: "music", UseAgents: []string{"playlist"}},
+				{Name: "playlist", UseAgents: []string{"music"}},
+			},
+			expectError: "agent reference cycle detected: music -> playlist -> music",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := Configuration{Agents: make([]Agent, len(c.agents))}
+			for i, a := range c.agents {
+				cfg.Agents[i] = Agent{AgentDefinition: a}
+			}
+
+			err := cfg.validateAgents()
+			if c.expectError != "" {
+				require.EqualError(t, err, c.expectError)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -11,7 +11,7 @@ try {
 
   const player = new RealTimeAudioPlayer()
   const websocket = new PbWebsocket({
-	url: `wss://${window.location.host}/channels/default/audio?buffer-ms=50`,
+	url: `wss://${window.location.host}/channels/default/audio?buffer-ms=50&locale=${encodeURIComponent(navigator.language)}`,
 	player: player
   })
   const myvad = await MicVAD.new({
@@ -25,6 +25,19 @@ try {
     },
   });
 
+  const imageInput = document.querySelector('#image')
+  const imagePreview = document.querySelector('#image-preview')
+  imageInput.addEventListener('change', async () => {
+    const file = imageInput.files[0]
+    if (!file) {
+      return
+    }
+    websocket.sendImage(new Uint8Array(await file.arrayBuffer()), file.type)
+    imagePreview.src = URL.createObjectURL(file)
+    imagePreview.hidden = false
+    imageInput.value = ''
+  })
+
   player.start()
   websocket.start()
   myvad.start()
@@ -66,6 +66,19 @@ export class PbWebsocket {
     }
   }
 
+  // sendImage sends an image that is attached to the next voice request.
+  sendImage(data, mimeType) {
+    if (!(data instanceof Uint8Array)) {
+      throw new Error("sendImage(buf, mimeType) expects a Uint8Array but got "+data);
+    }
+    if (this.ws && this.ws.readyState === WebSocket.OPEN) {
+      const msg = chat.Message.create({'images': [{'mimeType': mimeType, 'data': data}]});
+      this.ws.send(chat.Message.encode(msg).finish());
+    } else {
+      console.warn("WebSocket is not open. Unable to send image.");
+    }
+  }
+
   stop() {
     if (this.ws) {
       this.ws.close();